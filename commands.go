@@ -0,0 +1,269 @@
+/*
+ * File: commands.go
+ * Project: fanyi
+ * File Created: Saturday, 17th October 2026 6:32:20 am
+ * Author: Mark Mester (mmester6016@gmail.com)
+ * -----
+ * Last Modified: Saturday, 17th October 2026 7:08:22 am
+ * Modified By: Mark Mester (mmester6016@gmail.com>)
+ */
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	slackbot "github.com/markmester/fanyi-slackbot/pkg/bot"
+	clients "github.com/markmester/fanyi-slackbot/pkg/clients"
+)
+
+const commandUsage = `Usage:
+  fanyi                                  run the bot
+  fanyi config show   [-datastore PATH]  summarize the stored configuration
+  fanyi config export [-datastore PATH] [-o FILE]
+                                         write the configuration document as JSON (default stdout)
+  fanyi config import [-datastore PATH] [FILE]
+                                         validate a configuration document (default stdin) and store it
+  fanyi config encrypt [-datastore PATH] encrypt the unencrypted data of the datastore with DATASTORE_ENCRYPTION_KEY
+  fanyi config scope  [-datastore PATH] -team ID
+                                         scope the configuration to its workspace before setting SLACK_CLIENT_ID
+
+The datastore defaults to DATASTORE_PATH. To move a deployment, e.g. from a
+local directory to S3:
+
+  fanyi config export -datastore ./store | fanyi config import -datastore s3://bucket/prefix`
+
+// runCommand runs a subcommand of the binary
+func runCommand(args []string) error {
+	if args[0] != "config" || len(args) < 2 {
+		return fmt.Errorf("%s", commandUsage)
+	}
+
+	flags := flag.NewFlagSet("config "+args[1], flag.ContinueOnError)
+	path := flags.String("datastore", datastorePath, "datastore path, e.g. ./store, bolt:///data/fanyi.db or s3://bucket/prefix")
+	output := flags.String("o", "", "file to export to")
+	team := flags.String("team", "", "team ID of the workspace, e.g. T0123ABCD")
+	if err := flags.Parse(args[2:]); err != nil {
+		return err
+	}
+
+	datastore, err := newDatastore(*path)
+	if err != nil {
+		return err
+	}
+	if isNooP(datastore) {
+		return fmt.Errorf("'%s' is not a datastore; expected an existing directory, bolt:// or s3:// path", *path)
+	}
+	if closer, ok := datastore.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	switch args[1] {
+	case "show":
+		return showConfig(datastore, *path, os.Stdout)
+	case "export":
+		return exportConfig(datastore, *path, *output)
+	case "import":
+		return importConfig(datastore, flags.Arg(0))
+	case "encrypt":
+		return encryptConfig(datastore, *path)
+	case "scope":
+		return scopeConfig(datastore, *path, *team)
+	default:
+		return fmt.Errorf("%s", commandUsage)
+	}
+}
+
+// readConfig reads the stored document, upgraded to the current schema
+func readConfig(datastore clients.DataStore, path string) (*slackbot.Document, error) {
+	doc, _, err := slackbot.ReadDocument(datastore)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no configuration stored in '%s'", path)
+	}
+	return doc, err
+}
+
+func showConfig(datastore clients.DataStore, path string, out io.Writer) error {
+	doc, err := readConfig(datastore, path)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Schema version: %d\n", doc.SchemaVersion)
+	fmt.Fprintf(out, "Written at:     %s\n", doc.WrittenAt.Format(time.RFC3339))
+	fmt.Fprintf(out, "Bot version:    %s\n", doc.BotVersion)
+
+	fmt.Fprintf(out, "\nAuto-translation (%d channels):\n", len(doc.Channels))
+	for _, channel := range sortedKeys(doc.Channels) {
+		config := doc.Channels[channel]
+		languages := []string{}
+		for _, language := range config.Languages {
+			if dialect, ok := config.Dialects[language]; ok {
+				language = fmt.Sprintf("%s (%s)", language, dialect)
+			}
+			languages = append(languages, language)
+		}
+		fmt.Fprintf(out, "  %s: %s\n", channel, strings.Join(languages, " ↔ "))
+	}
+
+	fmt.Fprintf(out, "\nMirrors (%d channels):\n", len(doc.Mirrors))
+	for _, source := range sortedKeys(doc.Mirrors) {
+		for _, link := range doc.Mirrors[source] {
+			fmt.Fprintf(out, "  %s → %s (%s)\n", source, link.Target, link.Language)
+		}
+	}
+
+	fmt.Fprintf(out, "\nPersonal translations (%d channels):\n", len(doc.Personal))
+	for _, channel := range doc.Personal {
+		fmt.Fprintf(out, "  %s\n", channel)
+	}
+
+	fmt.Fprintf(out, "\nPreferred languages (%d users):\n", len(doc.Users))
+	for _, user := range sortedKeys(doc.Users) {
+		fmt.Fprintf(out, "  %s: %s\n", user, doc.Users[user].Language)
+	}
+
+	installations, err := clients.ReadInstallations(datastore)
+	if err != nil {
+		return err
+	}
+	if len(installations) > 0 {
+		fmt.Fprintf(out, "\nWorkspaces (%d):\n", len(installations))
+		for _, team := range sortedKeys(installations) {
+			fmt.Fprintf(out, "  %s: %s (installed %s)\n", team, installations[team].TeamName, installations[team].InstalledAt.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+func exportConfig(datastore clients.DataStore, path, output string) error {
+	doc, err := readConfig(datastore, path)
+	if err != nil {
+		return err
+	}
+	if doc.Teams, err = clients.ReadInstallations(datastore); err != nil {
+		return err
+	}
+	if len(doc.Teams) == 0 {
+		doc.Teams = nil
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if output == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(output, data, 0600)
+}
+
+func importConfig(datastore clients.DataStore, input string) error {
+	var data []byte
+	var err error
+	if input == "" || input == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(input)
+	}
+	if err != nil {
+		return err
+	}
+
+	doc, from, err := slackbot.ParseDocument(data)
+	if err != nil {
+		return fmt.Errorf("invalid configuration document: %s", err.Error())
+	}
+	if from < slackbot.CurrentSchemaVersion {
+		fmt.Fprintf(os.Stderr, "Upgraded document from schema version %d to %d\n", from, slackbot.CurrentSchemaVersion)
+	}
+
+	doc.WrittenAt = time.Now().UTC()
+	doc.BotVersion = version
+	if err := slackbot.WriteDocument(datastore, doc); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Imported configuration of %d channels, %d mirrors and %d users\n", len(doc.Channels), len(doc.Mirrors), len(doc.Users))
+	if doc.Teams != nil {
+		fmt.Fprintf(os.Stderr, "Imported installations of %d workspaces\n", len(doc.Teams))
+	}
+	return nil
+}
+
+// scopeConfig scopes the configuration of a single workspace deployment to
+// its team; deployments serving several workspaces ignore unscoped keys
+func scopeConfig(datastore clients.DataStore, path, team string) error {
+	if team == "" || strings.Contains(team, ":") {
+		return fmt.Errorf("a team ID is required, e.g. -team T0123ABCD")
+	}
+
+	doc, err := readConfig(datastore, path)
+	if err != nil {
+		return err
+	}
+	unscoped := doc.Unscoped()
+	if len(unscoped) == 0 {
+		fmt.Fprintf(os.Stderr, "The configuration in '%s' is already scoped to workspaces\n", path)
+		return nil
+	}
+
+	doc.Scope(team)
+	doc.WrittenAt = time.Now().UTC()
+	doc.BotVersion = version
+	if err := slackbot.WriteDocument(datastore, doc); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Scoped %d channel and user settings to team %s\n", len(unscoped), team)
+	return nil
+}
+
+// encryptConfig encrypts the data stored before encryption was enabled,
+// which the bot otherwise refuses to read
+func encryptConfig(datastore clients.DataStore, path string) error {
+	encrypted, ok := datastore.(*clients.EncryptedStore)
+	if !ok {
+		return fmt.Errorf("set DATASTORE_ENCRYPTION_KEY or DATASTORE_ENCRYPTION_KEY_FILE to encrypt '%s'", path)
+	}
+
+	keys, err := encrypted.EncryptPlaintext()
+	for _, key := range keys {
+		fmt.Fprintf(os.Stderr, "Encrypted %s\n", key)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Encrypted %d objects in '%s'\n", len(keys), path)
+	return nil
+}
+
+// =========== Helpers ================ //
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// isNooP reports whether the datastore discards everything
+func isNooP(datastore clients.DataStore) bool {
+	if encrypted, ok := datastore.(*clients.EncryptedStore); ok {
+		datastore = encrypted.DataStore
+	}
+	_, ok := datastore.(*clients.NooPClient)
+	return ok
+}
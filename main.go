@@ -4,16 +4,22 @@
  * File Created: Tuesday, 24th January 2023 5:26:47 pm
  * Author: Mark Mester (mmester6016@gmail.com)
  * -----
- * Last Modified: Saturday, 28th January 2023 4:53:25 pm
+ * Last Modified: Saturday, 17th October 2026 6:42:10 am
  * Modified By: Mark Mester (mmester6016@gmail.com>)
  */
 package main
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"log"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	_ "github.com/joho/godotenv/autoload"
 
@@ -22,12 +28,32 @@ import (
 )
 
 var (
-	slackBotToken = getEnvOrPanic("SLACK_BOT_TOKEN")
-	slackAppToken = getEnvOrPanic("SLACK_APP_TOKEN")
-	chatGptApiKey = getEnvOrPanic("CHATGPT_API_KEY")
-	chatGptEngine = getEnvOrPanic("CHATGPT_COMPLETION_ENGINE")
+	slackClientID = os.Getenv("SLACK_CLIENT_ID")
 
-	datastorePath = os.Getenv("DATASTORE_PATH")
+	translationProvider = os.Getenv("TRANSLATION_PROVIDER")
+	translationApiKey   = getEnvOrDefault("TRANSLATION_API_KEY", os.Getenv("CHATGPT_API_KEY"))
+	translationModel    = getEnvOrDefault("TRANSLATION_MODEL", os.Getenv("CHATGPT_COMPLETION_ENGINE"))
+	translationBaseURL  = os.Getenv("TRANSLATION_BASE_URL")
+
+	translationTimeout          = getEnvDuration("TRANSLATION_TIMEOUT", clients.DefaultChainTimeout)
+	translationBreakerThreshold = getEnvInt("TRANSLATION_BREAKER_THRESHOLD", clients.DefaultBreakerThreshold)
+	translationBreakerCooldown  = getEnvDuration("TRANSLATION_BREAKER_COOLDOWN", clients.DefaultBreakerCooldown)
+
+	translationContextMessages = getEnvInt("TRANSLATION_CONTEXT_MESSAGES", slackbot.DefaultHistoryMessages)
+	translationContextTokens   = getEnvInt("TRANSLATION_CONTEXT_TOKENS", slackbot.DefaultHistoryTokens)
+
+	botWorkers   = getEnvInt("BOT_WORKERS", slackbot.DefaultWorkers)
+	botQueueSize = getEnvInt("BOT_QUEUE_SIZE", slackbot.DefaultQueueSize)
+
+	shutdownTimeout = getEnvDuration("SHUTDOWN_TIMEOUT", slackbot.DefaultShutdownTimeout)
+
+	version = getEnvOrDefault("VERSION", "dev")
+
+	datastorePath       = os.Getenv("DATASTORE_PATH")
+	datastoreS3Endpoint = os.Getenv("DATASTORE_S3_ENDPOINT")
+
+	datastoreEncryptionKey     = os.Getenv("DATASTORE_ENCRYPTION_KEY")
+	datastoreEncryptionKeyFile = os.Getenv("DATASTORE_ENCRYPTION_KEY_FILE")
 )
 
 func getEnvOrPanic(env string) string {
@@ -38,32 +64,184 @@ func getEnvOrPanic(env string) string {
 	return e
 }
 
+func getEnvOrDefault(env, def string) string {
+	if e := os.Getenv(env); e != "" {
+		return e
+	}
+	return def
+}
+
+func getEnvInt(env string, def int) int {
+	e := os.Getenv(env)
+	if e == "" {
+		return def
+	}
+	i, err := strconv.Atoi(e)
+	if err != nil {
+		panic(fmt.Sprintf("Invalid integer for environmental variable %s: %s", env, e))
+	}
+	return i
+}
+
+func getEnvDuration(env string, def time.Duration) time.Duration {
+	e := os.Getenv(env)
+	if e == "" {
+		return def
+	}
+	d, err := time.ParseDuration(e)
+	if err != nil {
+		panic(fmt.Sprintf("Invalid duration for environmental variable %s: %s", env, e))
+	}
+	return d
+}
+
+// newTranslator creates the translation provider(s) named by TRANSLATION_PROVIDER.
+// A comma separated list creates a fallback chain tried in the given order;
+// each provider may override the shared settings with TRANSLATION_<PROVIDER>_<SETTING>.
+func newTranslator() (clients.Translator, error) {
+	providers := strings.Split(translationProvider, ",")
+	if len(providers) == 1 {
+		return clients.NewTranslator(providers[0], clients.TranslatorConfig{
+			APIKey:  translationApiKey,
+			Model:   translationModel,
+			BaseURL: translationBaseURL,
+		})
+	}
+
+	chain := clients.NewTranslatorChain(clients.ChainConfig{
+		Timeout:          translationTimeout,
+		FailureThreshold: translationBreakerThreshold,
+		Cooldown:         translationBreakerCooldown,
+	})
+	for _, provider := range providers {
+		provider = strings.TrimSpace(provider)
+		prefix := "TRANSLATION_" + strings.ToUpper(provider) + "_"
+		translator, err := clients.NewTranslator(provider, clients.TranslatorConfig{
+			APIKey:  getEnvOrDefault(prefix+"API_KEY", translationApiKey),
+			Model:   getEnvOrDefault(prefix+"MODEL", translationModel),
+			BaseURL: getEnvOrDefault(prefix+"BASE_URL", translationBaseURL),
+		})
+		if err != nil {
+			return nil, err
+		}
+		chain.Add(provider, translator)
+	}
+
+	return chain, nil
+}
+
+// newSlackClient connects to slack in the configured mode: socket mode
+// (default) or through the public Events API
+func newSlackClient() *clients.SlackClient {
+	// Workspaces installed through OAuth have their own tokens
+	botToken := os.Getenv("SLACK_BOT_TOKEN")
+	if slackClientID == "" && botToken == "" {
+		getEnvOrPanic("SLACK_BOT_TOKEN")
+	}
+
+	switch mode := getEnvOrDefault("SLACK_MODE", "socket"); mode {
+	case "socket":
+		if slackClientID != "" {
+			panic("Multi-workspace installation (SLACK_CLIENT_ID) requires SLACK_MODE=http")
+		}
+		return clients.NewSlackClient(botToken, getEnvOrPanic("SLACK_APP_TOKEN"))
+	case "http":
+		return clients.NewSlackHTTPClient(botToken, getEnvOrPanic("SLACK_SIGNING_SECRET"), os.Getenv("SLACK_HTTP_ADDR"))
+	default:
+		panic(fmt.Sprintf("Invalid SLACK_MODE '%s'; expected socket or http", mode))
+	}
+}
+
+// newSlackPool serves every workspace the app is installed in through the
+// OAuth v2 install flow on the Events API server
+func newSlackPool(receiver *clients.SlackClient, datastore clients.DataStore) (*clients.SlackPool, error) {
+	pool, err := clients.NewSlackPool(receiver, datastore)
+	if err != nil {
+		return nil, err
+	}
+
+	handler := clients.NewSlackOAuthHandler(clients.SlackOAuthConfig{
+		ClientID:     slackClientID,
+		ClientSecret: getEnvOrPanic("SLACK_CLIENT_SECRET"),
+		RedirectURL:  getEnvOrPanic("SLACK_REDIRECT_URL"),
+	}, pool)
+	for _, path := range []string{clients.SlackInstallPath, clients.SlackOAuthRedirectPath} {
+		if err := receiver.Handle(path, handler); err != nil {
+			return nil, err
+		}
+	}
+
+	return pool, nil
+}
+
+// newDatastore opens the datastore at path with the configured settings
+func newDatastore(path string) (clients.DataStore, error) {
+	encryptionKeys, err := clients.LoadEncryptionKeys(datastoreEncryptionKey, datastoreEncryptionKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return clients.NewDatastore(path, clients.DatastoreConfig{
+		S3Endpoint:     datastoreS3Endpoint,
+		EncryptionKeys: encryptionKeys,
+	})
+}
+
 func main() {
+	// Subcommands, e.g. `fanyi config show`
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Initialize clients
-	slackClient := clients.NewSlackClient(slackBotToken, slackAppToken)
-	gpt3Client := clients.NewGpt3Client(chatGptApiKey, chatGptEngine)
+	slackClient := newSlackClient()
+	translator, err := newTranslator()
+	if err != nil {
+		panic(err)
+	}
 	detector := clients.NewDetector()
-	datastore, err := clients.NewDatastore(datastorePath)
+	datastore, err := newDatastore(datastorePath)
 	if err != nil {
 		panic(err)
 	}
+	if closer, ok := datastore.(io.Closer); ok {
+		defer closer.Close()
+	}
 
 	// Initialize bot
-	bot, err := slackbot.New(slackClient, gpt3Client, detector, datastore)
+	opts := []slackbot.Option{
+		slackbot.WithHistory(translationContextMessages, translationContextTokens),
+		slackbot.WithWorkers(botWorkers, botQueueSize),
+		slackbot.WithShutdownTimeout(shutdownTimeout),
+		slackbot.WithVersion(version),
+	}
+	if slackClientID != "" {
+		pool, err := newSlackPool(slackClient, datastore)
+		if err != nil {
+			panic(err)
+		}
+		opts = append(opts, slackbot.WithSlackPool(pool))
+	}
+
+	bot, err := slackbot.New(slackClient, translator, detector, datastore, opts...)
 	if err != nil {
 		panic(err)
 	}
-	defer bot.Shutdown()
 
-	sigs := make(chan os.Signal, 1)
-	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
 	// Wake up!
-	go func() {
-		if err := bot.Process(); err != nil {
-			panic(err)
-		}
-	}()
+	slackClient.Start(ctx)
+	if err := bot.Process(ctx); err != nil {
+		log.Printf("error processing events: %s", err.Error())
+	}
 
-	<-sigs
+	// Stop receiving events, then let pending ones finish
+	slackClient.Close()
+	bot.Shutdown()
 }
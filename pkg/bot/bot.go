@@ -4,22 +4,21 @@
  * File Created: Tuesday, 24th January 2023 5:25:17 pm
  * Author: Mark Mester (mmester6016@gmail.com)
  * -----
- * Last Modified: Monday, 30th January 2023 8:55:08 pm
+ * Last Modified: Saturday, 17th October 2026 7:27:37 am
  * Modified By: Mark Mester (mmester6016@gmail.com>)
  */
 package slackbot
 
 import (
+	"context"
 	"embed"
 	"encoding/json"
 	"fmt"
 	"log"
-	"os"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/pkg/errors"
-
 	"github.com/patrickmn/go-cache"
 	"github.com/slack-go/slack"
 	"github.com/slack-go/slack/slackevents"
@@ -35,278 +34,572 @@ const (
 	cacheExpireDuration = 5 * time.Minute
 	// Cache purges expired items every 10 minutes
 	cacheCleanupDuration = 10 * time.Minute
-	// Datastore key
+	// Translation replies are tracked for edits and deletion for 1 day
+	replyExpireDuration = 24 * time.Hour
+	// Conversation history windows are cached for 30 minutes
+	historyExpireDuration = 30 * time.Minute
+	// Mirrored messages are remembered for threading for 1 week
+	mirrorExpireDuration = 7 * 24 * time.Hour
+	// Users are told their events were dropped at most once a minute per channel
+	busyNoticeDuration = time.Minute
+	// Edits and deletions wait up to 2 seconds for room in a full event queue
+	editQueueTimeout = 2 * time.Second
+	// Configuration changes are snapshotted once quiet for 5 seconds
+	snapshotDebounce = 5 * time.Second
+	// The configuration is snapshotted every 5 minutes regardless
+	snapshotInterval = 5 * time.Minute
+	// Datastore keys; mirrors are only read to migrate unversioned configs
 	datastoreKey = "store.json"
+	mirrorsKey   = "mirrors.json"
 )
 
 var (
 	ErrMsgInternalServerError = "An unexpected error occurred; please try again later!"
 	ErrMsgUnsupportedFlag     = "Sorry, the flag '%s' is not supported!"
 	ErrMsgUnknownLanguage     = "Sorry, we are unable to detect the language of provided text"
+	ErrMsgUnknownChannel      = "Sorry, I couldn't find the channel %s"
 
 	//go:embed templates/*
 	templates embed.FS
 )
 
-// Bot provides a control plane to both slack and gpts,
+// Bot provides a control plane to both slack and translators,
 // responding to messages in channels and providing translations
 type Bot struct {
-	slack     *clients.SlackClient
-	gpt       *clients.Gpt3Client
-	detector  *clients.Detector
-	datastore clients.DataStore
+	slack      *clients.SlackClient
+	translator clients.Translator
+	detector   *clients.Detector
+	mirrors    *Mirrors
+	// preferences hold users' languages and channels sent personal translations
+	preferences *Preferences
+	datastore   clients.DataStore
+	version     string
 
 	cache *cache.Cache
+	// replies maps source messages to the translations posted for them
+	replies *cache.Cache
+	// mirrored maps messages to their copies in mirror channels and back
+	mirrored *cache.Cache
+	// history caches recent messages of conversations as translation context
+	history         *cache.Cache
+	historyMessages int
+	historyTokens   int
+
+	// workers handle events concurrently, in order per channel; busy
+	// remembers who was told their event was dropped
+	workers         *workerPool
+	busy            *cache.Cache
+	workerNum       int
+	queueSize       int
+	shutdownTimeout time.Duration
+
+	// dirty is set while configuration changes haven't been saved;
+	// changed wakes the snapshot loop; conflicted is set once another writer
+	// changed the stored configuration. Shared with team scoped copies.
+	dirty      *int32
+	changed    chan struct{}
+	conflicted *int32
+	saveMu     *sync.Mutex
+
+	// teams holds the clients of each workspace when installed in several;
+	// events are then handled by a copy of the bot scoped to their team
+	teams *clients.SlackPool
+	team  string
+
+	// ctx is cancelled on Shutdown, abandoning in-flight translations
+	ctx    context.Context
+	cancel context.CancelFunc
 
 	logger *zap.SugaredLogger
 }
 
+// Option configures optional behaviour of a Bot
+type Option func(*Bot)
+
+// WithVersion sets the bot version recorded in persisted configuration
+func WithVersion(version string) Option {
+	return func(b *Bot) {
+		b.version = version
+	}
+}
+
+// WithWorkers sets how many events are handled concurrently, and how many
+// events each worker queues; events arriving while a queue is full are
+// dropped, telling their user to try again
+func WithWorkers(workers, queueSize int) Option {
+	return func(b *Bot) {
+		b.workerNum = workers
+		b.queueSize = queueSize
+	}
+}
+
+// WithShutdownTimeout sets how long pending events may take to finish on Shutdown
+func WithShutdownTimeout(timeout time.Duration) Option {
+	return func(b *Bot) {
+		b.shutdownTimeout = timeout
+	}
+}
+
+// WithSlackPool serves every workspace in the pool, handling each event
+// with its workspace's client and channel configuration
+func WithSlackPool(pool *clients.SlackPool) Option {
+	return func(b *Bot) {
+		b.teams = pool
+	}
+}
+
+// WithHistory sets how many preceding messages, up to an estimated token
+// budget, are given to the translator as context. Zero disables context.
+func WithHistory(messages, tokens int) Option {
+	return func(b *Bot) {
+		b.historyMessages = messages
+		b.historyTokens = tokens
+	}
+}
+
 // New creates a new bot, and subscribes to slack events for Process
 // to start processing
-func New(slackClient *clients.SlackClient, gpt3Client *clients.Gpt3Client, detector *clients.Detector, datastore clients.DataStore) (*Bot, error) {
+func New(slackClient *clients.SlackClient, translator clients.Translator, detector *clients.Detector, datastore clients.DataStore, opts ...Option) (*Bot, error) {
 	// Initialize logger
 	logger, err := zap.NewProduction()
 	if err != nil {
 		return nil, err
 	}
 
-	// Load our config if it exists
-	if config, err := datastore.Get(datastoreKey); err != nil {
-		if !os.IsNotExist(err) {
-			return nil, errors.Wrapf(err, "error retrieving config from datastore")
-		}
-	} else {
-		if err := detector.FromJSON(config); err != nil {
-			return nil, errors.Wrapf(err, "error loading config into detector")
-		}
-	}
+	ctx, cancel := context.WithCancel(context.Background())
 
 	bot := Bot{
-		slack:     slackClient,
-		gpt:       gpt3Client,
-		cache:     cache.New(cacheExpireDuration, cacheCleanupDuration),
-		datastore: datastore,
-		logger:    logger.Sugar(),
-		detector:  detector,
+		ctx:         ctx,
+		cancel:      cancel,
+		slack:       slackClient,
+		translator:  translator,
+		cache:       cache.New(cacheExpireDuration, cacheCleanupDuration),
+		replies:     cache.New(replyExpireDuration, cacheCleanupDuration),
+		mirrored:    cache.New(mirrorExpireDuration, cacheCleanupDuration),
+		mirrors:     NewMirrors(),
+		preferences: NewPreferences(),
+		history:     cache.New(historyExpireDuration, cacheCleanupDuration),
+		busy:        cache.New(busyNoticeDuration, cacheCleanupDuration),
+		dirty:       new(int32),
+		changed:     make(chan struct{}, 1),
+		conflicted:  new(int32),
+		saveMu:      &sync.Mutex{},
+
+		historyMessages: DefaultHistoryMessages,
+		historyTokens:   DefaultHistoryTokens,
+		workerNum:       DefaultWorkers,
+		queueSize:       DefaultQueueSize,
+		shutdownTimeout: DefaultShutdownTimeout,
+		datastore:       datastore,
+		version:         "dev",
+		logger:          logger.Sugar(),
+		detector:        detector,
+	}
+
+	for _, opt := range opts {
+		opt(&bot)
+	}
+
+	// Load our config if it exists
+	if err := bot.load(); err != nil {
+		return nil, err
 	}
+	// Auto-translation changes are written through as they are made
+	detector.OnChange(func(string) { bot.persist() })
+
+	bot.workers = newWorkerPool(bot.workerNum, bot.queueSize, bot.logger)
+	go bot.snapshotLoop()
 
 	return &bot, nil
 }
 
+// Shutdown lets pending events finish, abandoning them after the shutdown
+// timeout, and persists the configuration. Process must have returned.
 func (b *Bot) Shutdown() {
 	b.logger.Info("Bot shutting down; cleaning up")
-	jsonBytes, err := b.detector.ToJSON()
-	if err != nil {
-		b.logger.Error("error persisting configuration to datastore!")
-		return
+
+	if !b.workers.stop(b.shutdownTimeout) {
+		b.logger.Warnf("pending events did not finish within %s; abandoning %d events", b.shutdownTimeout, b.QueueDepth())
+		b.cancel()
+		b.workers.wait()
 	}
+	b.cancel()
 
-	if err := b.datastore.Set(datastoreKey, jsonBytes); err != nil {
-		b.logger.Error("error persisting configuration to datastore!")
-		return
+	if err := b.save(); err != nil {
+		b.logger.Errorf("error persisting configuration to datastore! %s", err.Error())
 	}
 }
 
+// QueueDepth returns the number of events waiting for or being handled by a worker
+func (b *Bot) QueueDepth() int {
+	return b.workers.depth()
+}
+
 // Process will:
 //  1. Listen to slack events
 //  2. On channel message, detect character encoding
 //  3. Perform appropriate translation
 //  4. Respond as thread reply
-func (b *Bot) Process() error {
+//
+// It returns when ctx is cancelled or the slack events are closed.
+func (b *Bot) Process(ctx context.Context) error {
 	b.logger.Info("Starting bot receive routine...")
-	for evt := range b.slack.Sock().Events {
-		switch evt.Type {
-		case socketmode.EventTypeEventsAPI:
-			eventsAPIEvent, _ := evt.Data.(slackevents.EventsAPIEvent)
-			switch eventsAPIEvent.Type {
-			case slackevents.CallbackEvent:
-				innerEvent := eventsAPIEvent.InnerEvent
-
-				switch ev := innerEvent.Data.(type) {
-				case *slackevents.ReactionAddedEvent:
-					var timestamp = ev.Item.Timestamp
-
-					err := func() error {
-						if user, found := b.cache.Get(ev.Item.Timestamp); found {
-							if user.(string) == ev.User {
-								b.logger.Infof("received duplicate message; skipping")
-								return nil
-							}
-						}
-						b.cache.Set(ev.Item.Timestamp, ev.User, cache.DefaultExpiration)
+	for {
+		select {
+		case <-ctx.Done():
+			b.logger.Info("Stopping bot receive routine")
+			return nil
+		case evt, ok := <-b.slack.Events():
+			if !ok {
+				b.logger.Info("Slack events closed; stopping bot receive routine")
+				return nil
+			}
+			b.handleEvent(evt)
+		}
+	}
+}
 
-						// Get associated slack message
-						msg, err := b.slack.GetMessage(ev.Item.Channel, ev.Item.Timestamp)
-						if err != nil {
-							b.logger.Errorf("unable to get associated msg for reaction; err=%s", err.Error())
-							return fmt.Errorf(ErrMsgInternalServerError)
-						}
-						timestamp = msg.Timestamp // update to thread timestamp
-
-						// Map emoji to language
-						targetLanguage, ok := GetLanguageCode(ev.Reaction)
-						if !ok {
-							if strings.HasPrefix("flag-", ev.Reaction) {
-								b.logger.Errorf("unable to get language corresponding to emoji reaction: %s", ev.Reaction)
-								return fmt.Errorf(ErrMsgUnsupportedFlag, ev.Reaction)
-							}
-							// Not a flag emoji
-							return nil
-						}
+// handleEvent acknowledges a slack event and queues it for a worker
+func (b *Bot) handleEvent(evt socketmode.Event) {
+	switch evt.Type {
+	case socketmode.EventTypeEventsAPI:
+		// Acknowledge first; translations may take longer than slack's deadline
+		b.slack.Ack(*evt.Request)
 
-						// Detect language
-						sourceLanguage, exists := b.detector.Detect(msg.Text)
-						if !exists {
-							b.logger.Errorf("unable to determine language of message")
-							return fmt.Errorf(ErrMsgUnknownLanguage)
-						}
+		eventsAPIEvent, _ := evt.Data.(slackevents.EventsAPIEvent)
+		switch eventsAPIEvent.Type {
+		case slackevents.CallbackEvent:
+			innerEvent := eventsAPIEvent.InnerEvent
 
-						// Translate
-						body, err := b.gpt.Translate(sourceLanguage, "", targetLanguage, "", msg.Text)
-						if err != nil {
-							b.logger.Errorf("unable to provide translation for msg=%s from %s->%s; err=%s", msg.Text, sourceLanguage, targetLanguage, err.Error())
-							return fmt.Errorf(ErrMsgInternalServerError)
-						}
+			tb, ok := b.forTeam(eventsAPIEvent.TeamID, eventsAPIEvent.EnterpriseID)
+			if !ok {
+				return
+			}
 
-						if err := b.slack.PostMessage(ev.Item.Channel,
-							slack.MsgOptionText(body, false),
-							slack.MsgOptionTS(msg.Timestamp),
-						); err != nil {
-							b.logger.Errorf("unable to post translation for msg=%s from %s->%s; err=%s", msg.Text, sourceLanguage, targetLanguage, err.Error())
-							return fmt.Errorf(ErrMsgInternalServerError)
-						}
+			switch ev := innerEvent.Data.(type) {
+			case *slackevents.ReactionAddedEvent:
+				if !b.workers.submit(tb.scope(ev.Item.Channel), func() {
+					if err := tb.handleReactionAddedEvent(ev); err != nil {
+						tb.postErrorMessage(ev.Item.Channel, ev.User, ev.Item.Timestamp)
+					}
+				}) {
+					tb.postBusyMessage(ev.Item.Channel, ev.User, ev.Item.Timestamp)
+				}
 
-						return nil
-					}()
+			case *slackevents.MessageEvent:
+				job := func() {
+					var err error
+					switch ev.SubType {
+					case "message_changed":
+						err = tb.handleMessageChangedEvent(ev)
+					case "message_deleted":
+						err = tb.handleMessageDeletedEvent(ev)
+					default:
+						err = tb.handleMessageEvent(ev)
+					}
 
 					if err != nil {
-						if err := b.slack.PostEphemeralMessage(
-							ev.Item.Channel,
-							ev.User,
-							slack.MsgOptionText("Sorry! Something went wrong. Please try again later!", false),
-							slack.MsgOptionTS(timestamp)); err != nil {
-							b.logger.Errorf("unable to post message; err=%s", err.Error())
+						timestamp := ev.TimeStamp
+						if ev.ThreadTimeStamp != "" {
+							timestamp = ev.ThreadTimeStamp
 						}
+						tb.postErrorMessage(ev.Channel, ev.User, timestamp)
 					}
+				}
 
-				case *slackevents.MessageEvent:
-					timestamp := ev.TimeStamp
-					if ev.ThreadTimeStamp != "" {
-						timestamp = ev.ThreadTimeStamp
+				// Dropping an edit or deletion would leave its translation
+				// stale, so they wait for room behind the message's translation
+				switch ev.SubType {
+				case "message_changed", "message_deleted":
+					b.workers.submitWait(tb.scope(ev.Channel), job, editQueueTimeout)
+				default:
+					if !b.workers.submit(tb.scope(ev.Channel), job) && ev.BotID == "" {
+						tb.postBusyMessage(ev.Channel, ev.User, ev.ThreadTimeStamp)
 					}
+				}
 
-					err := func() error {
-						// Event is a bot event or does not contain any text
-						if ev.BotID != "" || ev.Text == "" {
-							return nil
-						}
-
-						// Retrieve select detector for this channel
-						selectDetector, err := b.detector.GetSelectedDetector(ev.Channel)
-						if err != nil {
-							// Auto translation hasn't been configured
-							return nil
-						}
+			case *slackevents.AppUninstalledEvent:
+				b.uninstall(eventsAPIEvent.TeamID, eventsAPIEvent.EnterpriseID)
 
-						b.logger.Infof("Retrieved select detector for channel=%s: %s <-> %s", ev.Channel, selectDetector.Selected.L1.String(), selectDetector.Selected.L2.String())
+			case *slackevents.TokensRevokedEvent:
+				if len(ev.Tokens.Bot) > 0 {
+					b.uninstall(eventsAPIEvent.TeamID, eventsAPIEvent.EnterpriseID)
+				}
+			}
+		}
 
-						// Ignore Cache hits
-						if user, found := b.cache.Get(ev.TimeStamp); found {
-							if user.(string) == ev.User {
-								b.logger.Infof("received duplicate message; skipping")
-								return nil
-							}
-						}
-						b.cache.Set(ev.TimeStamp, ev.User, cache.DefaultExpiration)
+	case socketmode.EventTypeSlashCommand:
+		// Just like before, type cast to the correct event type, this time a SlashEvent
+		command, ok := evt.Data.(slack.SlashCommand)
+		if !ok {
+			b.logger.Infof("Could not type cast the message to a SlashCommand: %v", command)
+			return
+		}
 
-						// Detect language
-						sourceLanguage, err := selectDetector.Select(ev.Channel, ev.Text)
-						if err != nil {
-							b.logger.Errorf("error detecting language in channel=%s; err=%s", ev.Channel, err.Error())
-							return nil
-						}
+		// Acknowledge the request
+		b.slack.Ack(*evt.Request)
 
-						var targetLanguage string
-						switch {
-						case sourceLanguage == selectDetector.Selected.L1:
-							targetLanguage = selectDetector.Selected.L2.String()
-						case sourceLanguage == selectDetector.Selected.L2:
-							targetLanguage = selectDetector.Selected.L1.String()
-						default:
-							b.logger.Infof("source language not in configured auto-translation pair; skipping")
-							return nil
-						}
+		tb, ok := b.forTeam(command.TeamID, command.EnterpriseID)
+		if !ok {
+			return
+		}
 
-						b.logger.Infof("Translating the following between: %s<->%s: %s", sourceLanguage.String(), targetLanguage, ev.Text)
+		// handleSlashCommand will take care of the command
+		if !b.workers.submit(tb.scope(command.ChannelID), func() {
+			if err := tb.handleSlashCommand(command); err != nil {
+				b.logger.Infof("Could not process slash command: err=%s", err.Error())
+			}
+		}) {
+			tb.postBusyMessage(command.ChannelID, command.UserID, "")
+		}
 
-						// Translate
-						body, err := b.gpt.Translate(sourceLanguage.String(), "", targetLanguage, "", ev.Text)
-						if err != nil {
-							b.logger.Errorf("unable to provide translation for msg=%s from %s->%s; err=%s", ev.Text, sourceLanguage.String(), targetLanguage, err.Error())
-							return fmt.Errorf(ErrMsgInternalServerError)
-						}
+	case socketmode.EventTypeInteractive:
+		interaction, ok := evt.Data.(slack.InteractionCallback)
+		if !ok {
+			b.logger.Errorf("Could not type cast the message to a Interaction callback: err=%v", interaction)
+			return
+		}
 
-						// Reply in thread
-						if err := b.slack.PostMessage(ev.Channel,
-							slack.MsgOptionText(body, false),
-							slack.MsgOptionTS(timestamp),
-						); err != nil {
-							b.logger.Errorf("unable to post translation for msg=%s; err=%s", ev.Text, err.Error())
-							return fmt.Errorf(ErrMsgInternalServerError)
-						}
+		// Acknowledge first; a modal submission is only closed by the ack
+		b.slack.Ack(*evt.Request)
 
-						return nil
-					}()
+		tb, ok := b.forTeam(interaction.Team.ID, interaction.Enterprise.ID)
+		if !ok {
+			return
+		}
 
-					if err != nil {
-						if err := b.slack.PostEphemeralMessage(
-							ev.Channel,
-							ev.User,
-							slack.MsgOptionText("Sorry! Something went wrong. Please try again later!", false),
-							slack.MsgOptionTS(timestamp)); err != nil {
-							b.logger.Errorf("unable to post message; err=%s", err.Error())
-						}
-					}
-				}
+		job := func() {
+			if err := tb.handleInteractionEvent(interaction); err != nil {
+				b.logger.Infof("Could not process interaction: err=%s", err.Error())
 			}
+		}
 
-			b.slack.Ack(*evt.Request)
+		// Modals must be opened within 3 seconds of the trigger, so don't
+		// queue them behind translations
+		if opensView(interaction) {
+			b.workers.start(job)
+			return
+		}
 
-		case socketmode.EventTypeSlashCommand:
-			// Just like before, type cast to the correct event type, this time a SlashEvent
-			command, ok := evt.Data.(slack.SlashCommand)
-			if !ok {
-				b.logger.Infof("Could not type cast the message to a SlashCommand: %v", command)
-				continue
-			}
+		// Interactions are ordered with the events of their channel, as they
+		// update the same translations; the few without one are ordered by user
+		channel := interactionChannel(interaction)
+		key := channel
+		if key == "" {
+			key = interaction.User.ID
+		}
+		if !b.workers.submit(tb.scope(key), job) {
+			tb.postBusyMessage(channel, interaction.User.ID, "")
+		}
 
-			// Acknowledge the request
-			b.slack.Ack(*evt.Request)
+	} //end of switch
+}
 
-			// handleSlashCommand will take care of the command
-			if err := b.handleSlashCommand(command); err != nil {
-				b.logger.Infof("Could not process slash command: err=%s", err.Error())
-				continue
-			}
+// forTeam returns the bot scoped to the workspace an event was sent from,
+// or false if the app isn't installed there
+func (b *Bot) forTeam(teamID, enterpriseID string) (*Bot, bool) {
+	if b.teams == nil {
+		return b, true
+	}
 
-		case socketmode.EventTypeInteractive:
-			interaction, ok := evt.Data.(slack.InteractionCallback)
-			if !ok {
-				b.logger.Errorf("Could not type cast the message to a Interaction callback: err=%v", interaction)
-				continue
-			}
+	client, ok := b.teams.Client(teamID, enterpriseID)
+	if !ok {
+		b.logger.Errorf("received event of team=%s enterprise=%s the app isn't installed in; skipping", teamID, enterpriseID)
+		return nil, false
+	}
 
-			err := b.handleInteractionEvent(interaction)
-			if err != nil {
-				b.logger.Infof("Could not process slash command: err=%s", err.Error())
-				continue
-			}
-			b.slack.Ack(*evt.Request)
+	scoped := *b
+	scoped.slack = client
+	scoped.team = teamID
+	if scoped.team == "" {
+		scoped.team = enterpriseID
+	}
+	return &scoped, true
+}
+
+// uninstall forgets the token of a workspace the app was removed from
+func (b *Bot) uninstall(teamID, enterpriseID string) {
+	if b.teams == nil {
+		return
+	}
 
-		} //end of switch
+	id := teamID
+	if _, ok := b.teams.Client(teamID, ""); !ok {
+		id = enterpriseID
 	}
+	if err := b.teams.Uninstall(id); err != nil {
+		b.logger.Errorf("unable to remove installation of team=%s; err=%s", id, err.Error())
+	}
+}
 
-	return nil
+// scope returns the key of a channel's configuration, which is prefixed by
+// its team when serving several workspaces
+func (b *Bot) scope(channel string) string {
+	if b.team == "" {
+		return channel
+	}
+	return scopeKey(b.team, channel)
+}
+
+// postErrorMessage lets a user know their request could not be completed
+func (b *Bot) postErrorMessage(channel, user, timestamp string) {
+	if err := b.slack.PostEphemeralMessage(
+		channel,
+		user,
+		slack.MsgOptionText("Sorry! Something went wrong. Please try again later!", false),
+		slack.MsgOptionTS(timestamp)); err != nil {
+		b.logger.Errorf("unable to post message; err=%s", err.Error())
+	}
+}
+
+// postBusyMessage tells the user their event was dropped because the bot is
+// too busy, at most once per channel every busyNoticeDuration
+func (b *Bot) postBusyMessage(channel, user, timestamp string) {
+	if channel == "" || user == "" {
+		return
+	}
+	if err := b.busy.Add(b.scope(channel)+":"+user, true, cache.DefaultExpiration); err != nil {
+		return
+	}
+
+	// Posting could itself take a while, so don't hold up the events behind it
+	b.workers.start(func() {
+		if err := b.slack.PostEphemeralMessage(
+			channel,
+			user,
+			slack.MsgOptionText("Sorry! I'm too busy to keep up right now, so I skipped that. Please try again in a moment!", false),
+			slack.MsgOptionTS(timestamp)); err != nil {
+			b.logger.Errorf("unable to post message; err=%s", err.Error())
+		}
+	})
+}
+
+// handleReactionAddedEvent translates a message into the language of the flag it was reacted with
+func (b *Bot) handleReactionAddedEvent(ev *slackevents.ReactionAddedEvent) error {
+	if user, found := b.cache.Get(ev.Item.Timestamp); found {
+		if user.(string) == ev.User {
+			b.logger.Infof("received duplicate message; skipping")
+			return nil
+		}
+	}
+	b.cache.Set(ev.Item.Timestamp, ev.User, cache.DefaultExpiration)
+
+	// Map emoji to language
+	targetLanguage, ok := GetLanguageCode(ev.Reaction)
+	if !ok {
+		if strings.HasPrefix(ev.Reaction, "flag-") {
+			b.logger.Errorf("unable to get language corresponding to emoji reaction: %s", ev.Reaction)
+			return fmt.Errorf(ErrMsgUnsupportedFlag, ev.Reaction)
+		}
+		// Not a flag emoji
+		return nil
+	}
+
+	// Get associated slack message
+	msg, err := b.slack.GetMessage(ev.Item.Channel, ev.Item.Timestamp)
+	if err != nil {
+		b.logger.Errorf("unable to get associated msg for reaction; err=%s", err.Error())
+		return fmt.Errorf(ErrMsgInternalServerError)
+	}
+
+	// Detect language
+	sourceLanguage, exists := b.detector.Detect(msg.Text)
+	if !exists {
+		b.logger.Errorf("unable to determine language of message")
+		return fmt.Errorf(ErrMsgUnknownLanguage)
+	}
+
+	// Prefer the flag's dialect, falling back to any configured for the channel
+	targetDialect := GetDialect(ev.Reaction)
+	if targetDialect == "" {
+		targetDialect = b.detector.GetDialect(b.scope(ev.Item.Channel), targetLanguage)
+	}
+
+	// A thread parent's context is the channel, a reply's is its thread
+	thread := msg.ThreadTimestamp
+	if thread == msg.Timestamp {
+		thread = ""
+	}
+
+	return b.postTranslation(ev.Item.Channel, msg.Timestamp, msg.Timestamp, msg.Text, trackedReply{
+		SourceLanguage: sourceLanguage,
+		SourceDialect:  b.detector.GetDialect(b.scope(ev.Item.Channel), sourceLanguage),
+		Targets:        []translationTarget{{Language: targetLanguage, Dialect: targetDialect}},
+	}, clients.WithHistory(b.conversationContext(ev.Item.Channel, thread, msg.Timestamp)))
+}
+
+// handleMessageEvent auto-translates messages in channels configured for auto-translation
+func (b *Bot) handleMessageEvent(ev *slackevents.MessageEvent) error {
+	// Event is a bot event or does not contain any text
+	if ev.BotID != "" || ev.Text == "" {
+		return nil
+	}
+
+	// Ignore Cache hits
+	if user, found := b.cache.Get(ev.TimeStamp); found {
+		if user.(string) == ev.User {
+			b.logger.Infof("received duplicate message; skipping")
+			return nil
+		}
+	}
+	b.cache.Set(ev.TimeStamp, ev.User, cache.DefaultExpiration)
+	b.recordHistory(ev)
+
+	// Mirror into linked channels, and replies in mirrors back to their source
+	if links := b.mirrors.Get(b.scope(ev.Channel)); len(links) > 0 {
+		if err := b.mirrorMessage(ev, links); err != nil {
+			return err
+		}
+	}
+	if err := b.mirrorReply(ev); err != nil {
+		return err
+	}
+
+	// Members are translated for privately instead of in the thread
+	if b.preferences.IsPersonal(b.scope(ev.Channel)) {
+		return b.postPersonalTranslations(ev)
+	}
+
+	// Retrieve select detector for this channel
+	selectDetector, err := b.detector.GetSelectedDetector(b.scope(ev.Channel))
+	if err != nil {
+		// Auto translation hasn't been configured
+		return nil
+	}
+
+	b.logger.Infof("Retrieved select detector for channel=%s: %s", ev.Channel, selectDetector.Selected)
+
+	// Detect language
+	sourceLanguage, err := selectDetector.Select(ev.Channel, ev.Text)
+	if err != nil {
+		b.logger.Errorf("error detecting language in channel=%s; err=%s", ev.Channel, err.Error())
+		return nil
+	}
+
+	// Translate into all other selected languages
+	languages := selectDetector.Selected.Targets(sourceLanguage)
+	if len(languages) == len(selectDetector.Selected.Languages) {
+		b.logger.Infof("source language not in configured auto-translation languages; skipping")
+		return nil
+	}
+	targets := []translationTarget{}
+	for _, language := range languages {
+		targets = append(targets, translationTarget{Language: language.String(), Dialect: selectDetector.Selected.Dialect(language)})
+	}
+
+	b.logger.Infof("Translating the following from %s into %d languages: %s", sourceLanguage.String(), len(targets), ev.Text)
+
+	// Reply in thread
+	threadTimestamp := ev.TimeStamp
+	if ev.ThreadTimeStamp != "" {
+		threadTimestamp = ev.ThreadTimeStamp
+	}
+
+	return b.postTranslation(ev.Channel, ev.TimeStamp, threadTimestamp, ev.Text, trackedReply{
+		SourceLanguage: sourceLanguage.String(),
+		SourceDialect:  selectDetector.Selected.Dialect(sourceLanguage),
+		Targets:        targets,
+	}, clients.WithHistory(b.messageContext(ev)))
 }
 
 // handleSlashCommand will take a slash command and route to the appropriate function
@@ -334,28 +627,59 @@ func (b *Bot) handleInteractionEvent(interaction slack.InteractionCallback) erro
 				for _, opt := range action.SelectedOptions {
 					selectedOptions = append(selectedOptions, opt.Text.Text)
 				}
-				if len(selectedOptions) == 2 {
-					if ok, err := b.detector.UpdateSelected(interaction.Channel.ID, selectedOptions[0], selectedOptions[1]); err != nil {
-						return err
-					} else if ok {
-						b.logger.Infof("updated auto-translation selection to %s:%s", selectedOptions[0], selectedOptions[1])
+				if len(selectedOptions) >= 2 {
+					// The picker fires on every change; only announce activation
+					_, err := b.detector.GetSelectedDetector(b.scope(interaction.Channel.ID))
+					wasActive := err == nil
 
-						if err := b.slack.PostMessage(
+					ok, err := b.detector.UpdateSelected(b.scope(interaction.Channel.ID), selectedOptions...)
+					if err != nil {
+						return err
+					}
+					if ok {
+						b.logger.Infof("updated auto-translation selection to %s", strings.Join(selectedOptions, ":"))
+					}
+					if ok && !wasActive {
+						if _, err := b.slack.PostMessage(
 							interaction.Channel.ID,
-							slack.MsgOptionText(fmt.Sprintf("Auto-translation activated: %s  ↔  %s ", selectedOptions[0], selectedOptions[1]), false),
+							slack.MsgOptionText(fmt.Sprintf("Auto-translation activated: %s ", strings.Join(selectedOptions, "  ↔  ")), false),
 							slack.MsgOptionTS(interaction.Message.Timestamp)); err != nil {
 							return fmt.Errorf("failed to post message: %s", err.Error())
 						}
 					}
 				} else {
-					if err := b.slack.PostMessage(
+					if _, err := b.slack.PostMessage(
 						interaction.Channel.ID,
-						slack.MsgOptionText("Please select 2 languages.", false),
+						slack.MsgOptionText("Please select at least 2 languages.", false),
 						slack.MsgOptionTS(interaction.Message.Timestamp)); err != nil {
 						return fmt.Errorf("failed to post message: %s", err.Error())
 					}
 				}
 			}
+
+			if action.ActionID == "plain_text_input-dialect-select" {
+				if err := b.handleDialectSelection(interaction, action.Value); err != nil {
+					return err
+				}
+			}
+
+			switch action.ActionID {
+			case actionRetry, actionLiteral, actionExplain:
+				if err := b.handleFeedbackAction(interaction, action); err != nil {
+					return err
+				}
+			}
+		}
+	case slack.InteractionTypeMessageAction:
+		if interaction.CallbackID == callbackTranslateMessage {
+			return b.handleTranslateShortcut(interaction)
+		}
+	case slack.InteractionTypeViewSubmission:
+		switch interaction.View.CallbackID {
+		case callbackRetry:
+			return b.handleRetrySubmission(interaction)
+		case callbackTranslateModal:
+			return b.handleTranslateSubmission(interaction)
 		}
 	default:
 		// NooP
@@ -364,18 +688,122 @@ func (b *Bot) handleInteractionEvent(interaction slack.InteractionCallback) erro
 	return nil
 }
 
-// handleTranslateCommand will trigger a prompt to select between a common list of translation languages
-func (b *Bot) handleTranslateCommand(command slack.SlashCommand) error {
-	if strings.Contains(command.Text, "stop") {
-		b.logger.Info("stopping auto-translation")
-		b.detector.ClearSelected(command.ChannelID)
-		if err := b.slack.PostMessage(command.ChannelID,
-			slack.MsgOptionText("Stopping auto-translation!", false)); err != nil {
-			return err
+// interactionChannel returns the channel an interaction acts on. Modal
+// submissions carry it in the private metadata of their view.
+func interactionChannel(interaction slack.InteractionCallback) string {
+	if interaction.Channel.ID != "" {
+		return interaction.Channel.ID
+	}
+
+	if interaction.Type == slack.InteractionTypeViewSubmission {
+		switch interaction.View.CallbackID {
+		case callbackRetry:
+			var metadata retryMetadata
+			if err := json.Unmarshal([]byte(interaction.View.PrivateMetadata), &metadata); err == nil {
+				return metadata.Channel
+			}
+		case callbackTranslateModal:
+			var metadata translateMetadata
+			if err := json.Unmarshal([]byte(interaction.View.PrivateMetadata), &metadata); err == nil {
+				return metadata.Channel
+			}
+		}
+	}
+	return ""
+}
+
+// opensView reports whether handling the interaction opens a modal
+func opensView(interaction slack.InteractionCallback) bool {
+	switch interaction.Type {
+	case slack.InteractionTypeMessageAction:
+		return interaction.CallbackID == callbackTranslateMessage
+	case slack.InteractionTypeBlockActions:
+		for _, action := range interaction.ActionCallback.BlockActions {
+			if action.ActionID == actionRetry {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// handleDialectSelection configures the dialects given as a comma separated
+// list of "<language>: <dialect>" pairs, e.g. "Chinese: Wuhan, English: British"
+func (b *Bot) handleDialectSelection(interaction slack.InteractionCallback, value string) error {
+	reply := func(text string) error {
+		if _, err := b.slack.PostMessage(
+			interaction.Channel.ID,
+			slack.MsgOptionText(text, false),
+			slack.MsgOptionTS(interaction.Message.Timestamp)); err != nil {
+			return fmt.Errorf("failed to post message: %s", err.Error())
+		}
+		return nil
+	}
+
+	if _, err := b.detector.GetSelectedDetector(b.scope(interaction.Channel.ID)); err != nil {
+		return reply("Please select at least 2 languages before choosing dialects.")
+	}
+
+	updated := []string{}
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		language, dialect, found := strings.Cut(pair, ":")
+		if !found {
+			return reply(fmt.Sprintf("Unable to parse '%s'; dialects should be given as <language>: <dialect>", strings.TrimSpace(pair)))
 		}
+
+		ok, err := b.detector.UpdateDialect(b.scope(interaction.Channel.ID), language, dialect)
+		if err != nil {
+			return reply(fmt.Sprintf("Unable to set dialect: %s", err.Error()))
+		}
+		if ok {
+			updated = append(updated, fmt.Sprintf("%s (%s)", strings.TrimSpace(language), strings.TrimSpace(dialect)))
+		}
+	}
+
+	if len(updated) == 0 {
 		return nil
 	}
 
+	b.logger.Infof("updated auto-translation dialects for channel=%s: %s", interaction.Channel.ID, strings.Join(updated, ", "))
+	return reply(fmt.Sprintf("Auto-translation dialects set: %s", strings.Join(updated, ", ")))
+}
+
+// handleTranslateCommand routes a `/translate` command to its subcommand;
+// without one, a prompt to select between a common list of translation
+// languages is posted
+func (b *Bot) handleTranslateCommand(command slack.SlashCommand) error {
+	cmd, err := parseTranslateCommand(command.Text)
+	if err != nil {
+		return b.postEphemeral(command, err.Error())
+	}
+
+	switch cmd.Name {
+	case "on":
+		return b.handleOnCommand(command, cmd.Args)
+	case "off":
+		return b.handleOffCommand(command)
+	case "status":
+		return b.handleStatusCommand(command)
+	case "text":
+		return b.handleTextCommand(command, cmd.Args[0], cmd.Args[1])
+	case "detect":
+		return b.handleDetectCommand(command, cmd.Args[0])
+	case "languages":
+		return b.handleLanguagesCommand(command)
+	case "mirror":
+		return b.handleMirrorCommand(command, cmd.Args)
+	case "me":
+		return b.handleMeCommand(command, cmd.Args)
+	case "personal":
+		return b.handlePersonalCommand(command, cmd.Args)
+	case "help":
+		return b.postEphemeral(command, translateUsage())
+	}
+
 	data, err := templates.ReadFile("templates/language_select.json")
 	if err != nil {
 		return err
@@ -389,7 +817,7 @@ func (b *Bot) handleTranslateCommand(command slack.SlashCommand) error {
 
 	var options []slack.MsgOption
 	options = append(options, slack.MsgOptionBlocks(blocks.Blocks...))
-	if err := b.slack.PostMessage(command.ChannelID, options...); err != nil {
+	if _, err := b.slack.PostMessage(command.ChannelID, options...); err != nil {
 		return err
 	}
 
@@ -409,17 +837,17 @@ Please see below for a list of available commands:
 
 • /help → Display this help message.
 
-• /translate → Automatically detect and translate between the specified languages.
+• /translate → Automatically detect and translate between the specified languages, optionally in a specific dialect of each (e.g. Chinese: Wuhan).
 
-• /translate stop → Stop auto-translation.
+` + translateUsage() + `
 
-• flag emoji → React to any message with a flag emoji (🇺🇸) and Fanyi will respond with the translation of that flags language.
+• flag emoji → React to any message with a flag emoji (🇺🇸) and Fanyi will respond with the translation of that flags language (and dialect, e.g. 🇧🇷 for Brazilian Portuguese).
 `,
 	}
 
 	// Send the message to the channel
 	// The Channel is available in the command.ChannelID
-	if err := b.slack.PostMessage(command.ChannelID, slack.MsgOptionAttachments(attachment)); err != nil {
+	if _, err := b.slack.PostMessage(command.ChannelID, slack.MsgOptionAttachments(attachment)); err != nil {
 		return fmt.Errorf("failed to post message: %s", err.Error())
 	}
 	return nil
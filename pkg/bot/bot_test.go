@@ -0,0 +1,48 @@
+/*
+ * File: bot_test.go
+ * Project: bot
+ * File Created: Saturday, 17th October 2026 7:27:37 am
+ * Author: Mark Mester (mmester6016@gmail.com)
+ * -----
+ * Last Modified: Saturday, 17th October 2026 7:27:37 am
+ * Modified By: Mark Mester (mmester6016@gmail.com>)
+ */
+package slackbot
+
+import (
+	"testing"
+
+	"github.com/slack-go/slack"
+)
+
+func TestInteractionChannel(t *testing.T) {
+	submission := func(callbackID, metadata string) slack.InteractionCallback {
+		interaction := slack.InteractionCallback{Type: slack.InteractionTypeViewSubmission}
+		interaction.View.CallbackID = callbackID
+		interaction.View.PrivateMetadata = metadata
+		return interaction
+	}
+	action := slack.InteractionCallback{Type: slack.InteractionTypeBlockActions}
+	action.Channel.ID = "C1"
+
+	tests := []struct {
+		name        string
+		interaction slack.InteractionCallback
+		want        string
+	}{
+		{name: "block action", interaction: action, want: "C1"},
+		// Retries update the translation edits and deletions of the source message update
+		{name: "retry submission", interaction: submission(callbackRetry, `{"ref": {"ts": "1.2"}, "c": "C2", "r": "1.3"}`), want: "C2"},
+		{name: "translate submission", interaction: submission(callbackTranslateModal, `{"c": "C3", "ts": "1.2"}`), want: "C3"},
+		{name: "invalid metadata", interaction: submission(callbackRetry, `{`), want: ""},
+		{name: "other submission", interaction: submission("other", `{"c": "C4"}`), want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if channel := interactionChannel(tt.interaction); channel != tt.want {
+				t.Errorf("interactionChannel = %q, want %q", channel, tt.want)
+			}
+		})
+	}
+}
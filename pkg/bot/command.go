@@ -0,0 +1,251 @@
+/*
+ * File: command.go
+ * Project: bot
+ * File Created: Saturday, 17th October 2026 6:48:45 am
+ * Author: Mark Mester (mmester6016@gmail.com)
+ * -----
+ * Last Modified: Saturday, 17th October 2026 7:19:08 am
+ * Modified By: Mark Mester (mmester6016@gmail.com>)
+ */
+package slackbot
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/slack-go/slack"
+
+	"github.com/markmester/fanyi-slackbot/pkg/clients"
+)
+
+// subcommand describes the grammar of a `/translate` subcommand
+type subcommand struct {
+	Name        string
+	Usage       string
+	Description string
+
+	// MinArgs and MaxArgs bound the number of arguments; MaxArgs < 0 is unbounded
+	MinArgs, MaxArgs int
+	// Rest makes the text following MinArgs arguments, verbatim, a final
+	// argument which must not be empty (e.g. the message of `text`)
+	Rest bool
+}
+
+// subcommands of `/translate`, in the order shown by help
+var subcommands = []subcommand{
+	{Name: "on", Usage: "on <language> <language> [<language>...]", MinArgs: 2, MaxArgs: -1,
+		Description: "Auto-translate this channel between the given languages."},
+	{Name: "off", Usage: "off",
+		Description: "Stop auto-translation."},
+	{Name: "status", Usage: "status",
+		Description: "Show the translation settings of this channel."},
+	{Name: "text", Usage: "text <language> <message>", MinArgs: 1, Rest: true,
+		Description: "Privately translate a message into <language>."},
+	{Name: "detect", Usage: "detect <message>", Rest: true,
+		Description: "Privately show the language of a message."},
+	{Name: "languages", Usage: "languages",
+		Description: "List the supported languages."},
+	{Name: "mirror", Usage: "mirror #channel <language> | mirror stop [#channel]", MaxArgs: -1,
+		Description: "Mirror this channel into #channel, translated to <language>, with thread replies in #channel translated back; or stop mirroring."},
+	{Name: "me", Usage: "me [<language>|off]", MaxArgs: -1,
+		Description: "Show, set or clear your preferred language."},
+	{Name: "personal", Usage: "personal on|off", MinArgs: 1, MaxArgs: 1,
+		Description: "Privately send each member a translation into their preferred language, instead of replying in the thread."},
+	{Name: "help", Usage: "help",
+		Description: "Show this list of commands."},
+}
+
+// translateCommand is a parsed `/translate` command
+type translateCommand struct {
+	// Name of the subcommand; empty for a bare `/translate`
+	Name string
+	Args []string
+}
+
+// parseTranslateCommand parses the text of a `/translate` command. The
+// returned error is a usage message meant for the user.
+func parseTranslateCommand(text string) (translateCommand, error) {
+	name, rest := nextArg(text)
+	if name == "" {
+		return translateCommand{}, nil
+	}
+
+	name = strings.ToLower(name)
+	if name == "stop" {
+		// `stop` predates `off`
+		name = "off"
+	}
+
+	var sub *subcommand
+	for i := range subcommands {
+		if subcommands[i].Name == name {
+			sub = &subcommands[i]
+		}
+	}
+	if sub == nil {
+		return translateCommand{}, fmt.Errorf("Unknown command '%s'. See `/translate help` for a list of commands.", name)
+	}
+	usage := fmt.Errorf("Usage: `/translate %s`", sub.Usage)
+
+	args := []string{}
+	if sub.Rest {
+		for i := 0; i < sub.MinArgs; i++ {
+			var arg string
+			if arg, rest = nextArg(rest); arg == "" {
+				return translateCommand{}, usage
+			}
+			args = append(args, arg)
+		}
+		if rest = strings.TrimFunc(rest, isArgSeparator); rest == "" {
+			return translateCommand{}, usage
+		}
+		return translateCommand{Name: name, Args: append(args, rest)}, nil
+	}
+
+	for arg, rest := nextArg(rest); arg != ""; arg, rest = nextArg(rest) {
+		args = append(args, arg)
+	}
+	if len(args) < sub.MinArgs || (sub.MaxArgs >= 0 && len(args) > sub.MaxArgs) {
+		return translateCommand{}, usage
+	}
+	return translateCommand{Name: name, Args: args}, nil
+}
+
+// translateUsage lists the `/translate` subcommands
+func translateUsage() string {
+	lines := []string{}
+	for _, sub := range subcommands {
+		lines = append(lines, fmt.Sprintf("• /translate %s → %s", sub.Usage, sub.Description))
+	}
+	return strings.Join(lines, "\n\n")
+}
+
+// handleOnCommand handles `/translate on <language> <language> [<language>...]`
+func (b *Bot) handleOnCommand(command slack.SlashCommand, languages []string) error {
+	ok, err := b.detector.UpdateSelected(b.scope(command.ChannelID), languages...)
+	if err != nil {
+		return b.postEphemeral(command, fmt.Sprintf("Sorry, %s.", err.Error()))
+	}
+	if !ok {
+		return b.postEphemeral(command, "Auto-translation is already set to these languages.")
+	}
+
+	b.logger.Infof("updated auto-translation selection to %s", strings.Join(languages, ":"))
+
+	if _, err := b.slack.PostMessage(command.ChannelID,
+		slack.MsgOptionText(fmt.Sprintf("Auto-translation activated: %s ", strings.Join(languages, "  ↔  ")), false)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// handleOffCommand handles `/translate off`
+func (b *Bot) handleOffCommand(command slack.SlashCommand) error {
+	b.logger.Info("stopping auto-translation")
+	b.detector.ClearSelected(b.scope(command.ChannelID))
+	if _, err := b.slack.PostMessage(command.ChannelID,
+		slack.MsgOptionText("Stopping auto-translation!", false)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// handleStatusCommand privately shows the translation settings of the channel
+func (b *Bot) handleStatusCommand(command slack.SlashCommand) error {
+	lines := []string{}
+
+	if selectDetector, err := b.detector.GetSelectedDetector(b.scope(command.ChannelID)); err != nil {
+		lines = append(lines, "Auto-translation: off")
+	} else {
+		languages := []string{}
+		for _, language := range selectDetector.Selected.Languages {
+			name := language.String()
+			if dialect := selectDetector.Selected.Dialect(language); dialect != "" {
+				name = fmt.Sprintf("%s (%s)", name, dialect)
+			}
+			languages = append(languages, name)
+		}
+		lines = append(lines, "Auto-translation: "+strings.Join(languages, " ↔ "))
+	}
+
+	for _, link := range b.mirrors.Get(b.scope(command.ChannelID)) {
+		lines = append(lines, fmt.Sprintf("Mirrored into <#%s> in %s", link.Target, link.Language))
+	}
+
+	personal := "off"
+	if b.preferences.IsPersonal(b.scope(command.ChannelID)) {
+		personal = "on"
+	}
+	lines = append(lines, "Personal translations: "+personal)
+
+	if language := b.preferences.Language(b.scope(command.UserID)); language != "" {
+		lines = append(lines, "Your preferred language: "+language)
+	}
+	lines = append(lines, fmt.Sprintf("Pending events: %d", b.QueueDepth()))
+
+	return b.postEphemeral(command, strings.Join(lines, "\n"))
+}
+
+// handleTextCommand privately translates a message for the user
+func (b *Bot) handleTextCommand(command slack.SlashCommand, language, text string) error {
+	body, err := b.translateOnDemand(language, text)
+	if err != nil {
+		return b.postEphemeral(command, err.Error())
+	}
+	return b.postEphemeral(command, body)
+}
+
+// translateOnDemand translates text of any detectable language into language.
+// The returned error is a message meant for the user.
+func (b *Bot) translateOnDemand(language, text string) (string, error) {
+	if clients.LanguageCode(language) == "" {
+		return "", fmt.Errorf("Sorry, '%s' is not a supported language.", language)
+	}
+
+	sourceLanguage, ok := b.detector.Detect(text)
+	if !ok {
+		return "", fmt.Errorf(ErrMsgUnknownLanguage)
+	}
+	if clients.LanguageCode(sourceLanguage) == clients.LanguageCode(language) {
+		return "", fmt.Errorf("That message is already in %s.", sourceLanguage)
+	}
+
+	body, err := b.translate(b.ctx, sourceLanguage, "", language, "", text)
+	if err != nil {
+		b.logger.Errorf("unable to provide translation for msg=%s from %s->%s; err=%s", text, sourceLanguage, language, err.Error())
+		return "", fmt.Errorf(ErrMsgInternalServerError)
+	}
+	return body, nil
+}
+
+// handleDetectCommand privately shows the language of a message
+func (b *Bot) handleDetectCommand(command slack.SlashCommand, text string) error {
+	language, ok := b.detector.Detect(text)
+	if !ok {
+		return b.postEphemeral(command, ErrMsgUnknownLanguage)
+	}
+	return b.postEphemeral(command, fmt.Sprintf("That message is in %s.", language))
+}
+
+// handleLanguagesCommand privately lists the supported languages
+func (b *Bot) handleLanguagesCommand(command slack.SlashCommand) error {
+	return b.postEphemeral(command, "Supported languages: "+strings.Join(clients.SupportedLanguages(), ", "))
+}
+
+// =========== Helpers ================ //
+
+// isArgSeparator reports whether r separates command arguments
+func isArgSeparator(r rune) bool {
+	return unicode.IsSpace(r) || r == ','
+}
+
+// nextArg splits the first whitespace or comma separated argument off text
+func nextArg(text string) (string, string) {
+	text = strings.TrimLeftFunc(text, isArgSeparator)
+	end := strings.IndexFunc(text, isArgSeparator)
+	if end < 0 {
+		return text, ""
+	}
+	return text[:end], text[end:]
+}
@@ -0,0 +1,93 @@
+/*
+ * File: command_test.go
+ * Project: bot
+ * File Created: Saturday, 17th October 2026 7:01:39 am
+ * Author: Mark Mester (mmester6016@gmail.com)
+ * -----
+ * Last Modified: Saturday, 17th October 2026 7:01:39 am
+ * Modified By: Mark Mester (mmester6016@gmail.com>)
+ */
+package slackbot
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTranslateCommand(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want translateCommand
+		err  string
+	}{
+		// Bare command posts the language picker
+		{name: "empty", text: "", want: translateCommand{}},
+		{name: "whitespace", text: "  \t", want: translateCommand{}},
+
+		// Unknown words
+		{name: "stopwatch", text: "stopwatch",
+			err: "Unknown command 'stopwatch'. See `/translate help` for a list of commands."},
+		{name: "quoted stopwatch", text: `"stopwatch"`,
+			err: "Unknown command '\"stopwatch\"'. See `/translate help` for a list of commands."},
+		{name: "stop as argument", text: "please stop",
+			err: "Unknown command 'please'. See `/translate help` for a list of commands."},
+
+		// Aliases and case
+		{name: "stop", text: "stop", want: translateCommand{Name: "off", Args: []string{}}},
+		{name: "STOP", text: "STOP", want: translateCommand{Name: "off", Args: []string{}}},
+		{name: "off", text: " off ", want: translateCommand{Name: "off", Args: []string{}}},
+
+		// Argument counts
+		{name: "on without languages", text: "on",
+			err: "Usage: `/translate on <language> <language> [<language>...]`"},
+		{name: "on with one language", text: "on English",
+			err: "Usage: `/translate on <language> <language> [<language>...]`"},
+		{name: "on with two languages", text: "on English Spanish",
+			want: translateCommand{Name: "on", Args: []string{"English", "Spanish"}}},
+		{name: "off with argument", text: "off now", err: "Usage: `/translate off`"},
+		{name: "status with argument", text: "status x", err: "Usage: `/translate status`"},
+		{name: "personal without argument", text: "personal", err: "Usage: `/translate personal on|off`"},
+		{name: "personal with two arguments", text: "personal on off", err: "Usage: `/translate personal on|off`"},
+		{name: "personal", text: "personal on", want: translateCommand{Name: "personal", Args: []string{"on"}}},
+		{name: "me without language", text: "me", want: translateCommand{Name: "me", Args: []string{}}},
+		{name: "me", text: "me Chinese Simplified", want: translateCommand{Name: "me", Args: []string{"Chinese", "Simplified"}}},
+		{name: "mirror", text: "mirror <#C123|general> German",
+			want: translateCommand{Name: "mirror", Args: []string{"<#C123|general>", "German"}}},
+
+		// Comma separated language lists
+		{name: "on with commas", text: "on English, Spanish,German",
+			want: translateCommand{Name: "on", Args: []string{"English", "Spanish", "German"}}},
+		{name: "on with trailing comma", text: "on English,Spanish,",
+			want: translateCommand{Name: "on", Args: []string{"English", "Spanish"}}},
+
+		// Rest of the line
+		{name: "text", text: "text Spanish Where is  the station?",
+			want: translateCommand{Name: "text", Args: []string{"Spanish", "Where is  the station?"}}},
+		{name: "text keeps commas in message", text: "text Spanish, hello, friend ",
+			want: translateCommand{Name: "text", Args: []string{"Spanish", "hello, friend"}}},
+		{name: "text without message", text: "text Spanish", err: "Usage: `/translate text <language> <message>`"},
+		{name: "text without language", text: "text", err: "Usage: `/translate text <language> <message>`"},
+		{name: "detect", text: "detect   bonjour tout le monde",
+			want: translateCommand{Name: "detect", Args: []string{"bonjour tout le monde"}}},
+		{name: "detect without message", text: "detect ", err: "Usage: `/translate detect <message>`"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTranslateCommand(tt.text)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("parseTranslateCommand(%q) error = %v, want %q", tt.text, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseTranslateCommand(%q) unexpected error: %s", tt.text, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTranslateCommand(%q) = %#v, want %#v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,524 @@
+/*
+ * File: document.go
+ * Project: bot
+ * File Created: Saturday, 17th October 2026 6:30:19 am
+ * Author: Mark Mester (mmester6016@gmail.com)
+ * -----
+ * Last Modified: Saturday, 17th October 2026 7:21:25 am
+ * Modified By: Mark Mester (mmester6016@gmail.com>)
+ */
+package slackbot
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pemistahl/lingua-go"
+	"github.com/pkg/errors"
+
+	"github.com/markmester/fanyi-slackbot/pkg/clients"
+)
+
+// backupNamespace holds copies of documents as they were before migration
+const backupNamespace = "backups"
+
+// Document is the persisted configuration of the bot. It is versioned
+// independently of the in-memory Detector and Mirrors so that their layout
+// can change without breaking previously saved documents.
+type Document struct {
+	SchemaVersion int       `json:"schema_version"`
+	WrittenAt     time.Time `json:"written_at"`
+	BotVersion    string    `json:"bot_version"`
+
+	// Channels maps channels to their auto-translation configuration
+	Channels map[string]ChannelConfig `json:"channels"`
+	// Mirrors maps source channels to the channels they are mirrored into
+	Mirrors map[string][]MirrorLink `json:"mirrors"`
+	// Users maps users to their preferences
+	Users map[string]UserConfig `json:"users"`
+	// Personal lists the channels whose members are sent personal translations
+	Personal []string `json:"personal"`
+
+	// Teams maps workspaces to the installation of the app, including its
+	// bot token. Installations are stored under their own keys, so this is
+	// only set in exports; when nil, stored installations are left as is.
+	Teams map[string]clients.SlackInstallation `json:"teams,omitempty"`
+}
+
+// ChannelConfig is the auto-translation configuration of a channel
+type ChannelConfig struct {
+	// Languages are the language names, e.g. "English"
+	Languages []string `json:"languages"`
+	// Dialects maps languages to the dialect used for them
+	Dialects map[string]string `json:"dialects,omitempty"`
+}
+
+// UserConfig holds the preferences of a user
+type UserConfig struct {
+	// Language is the user's preferred language, e.g. "Japanese"
+	Language string `json:"language"`
+}
+
+// migration upgrades a raw document by one schema version
+type migration func(doc map[string]json.RawMessage) error
+
+// migrations[n] upgrades a document from schema version n to n+1
+var migrations = []migration{
+	migrateV0,
+	migrateV1,
+}
+
+// CurrentSchemaVersion is the schema version of documents written by this bot
+var CurrentSchemaVersion = len(migrations)
+
+// ParseDocument decodes a document, upgrading it to the current schema
+// version. The version it was upgraded from is returned alongside.
+func ParseDocument(data []byte) (*Document, int, error) {
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, 0, errors.Wrapf(err, "invalid document")
+	}
+
+	// Documents without a version predate versioning
+	version := 0
+	if v, ok := raw["schema_version"]; ok {
+		if err := json.Unmarshal(v, &version); err != nil {
+			return nil, 0, errors.Wrapf(err, "invalid schema version")
+		}
+	}
+	if version > CurrentSchemaVersion {
+		return nil, version, fmt.Errorf("document schema version %d is newer than supported version %d", version, CurrentSchemaVersion)
+	}
+
+	for v := version; v < CurrentSchemaVersion; v++ {
+		if err := migrations[v](raw); err != nil {
+			return nil, version, errors.Wrapf(err, "unable to migrate document from schema version %d", v)
+		}
+		raw["schema_version"], _ = json.Marshal(v + 1)
+	}
+
+	migrated, err := json.Marshal(raw)
+	if err != nil {
+		return nil, version, err
+	}
+
+	doc := &Document{}
+	if err := json.Unmarshal(migrated, doc); err != nil {
+		return nil, version, errors.Wrapf(err, "invalid document")
+	}
+	if err := doc.Validate(); err != nil {
+		return nil, version, err
+	}
+
+	return doc, version, nil
+}
+
+// Validate checks the document is of the current schema and its
+// configuration can be applied
+func (d *Document) Validate() error {
+	if d.SchemaVersion != CurrentSchemaVersion {
+		return fmt.Errorf("unsupported schema version %d; expected %d", d.SchemaVersion, CurrentSchemaVersion)
+	}
+
+	for channel, config := range d.Channels {
+		if len(config.Languages) < 2 {
+			return fmt.Errorf("channel %s: expected at least 2 languages, got %d", channel, len(config.Languages))
+		}
+		for i, language := range config.Languages {
+			if clients.LanguageCode(language) == "" {
+				return fmt.Errorf("channel %s: unknown language '%s'", channel, language)
+			}
+			if selectedLanguage(config.Languages[:i], language) {
+				return fmt.Errorf("channel %s: language '%s' given more than once", channel, language)
+			}
+		}
+		for language := range config.Dialects {
+			if !selectedLanguage(config.Languages, language) {
+				return fmt.Errorf("channel %s: dialect given for unselected language '%s'", channel, language)
+			}
+		}
+	}
+
+	for source, links := range d.Mirrors {
+		for _, link := range links {
+			if link.Target == "" || clients.LanguageCode(link.Language) == "" {
+				return fmt.Errorf("channel %s: invalid mirror into '%s' (%s)", source, link.Target, link.Language)
+			}
+		}
+	}
+
+	for user, config := range d.Users {
+		if clients.LanguageCode(config.Language) == "" {
+			return fmt.Errorf("user %s: unknown language '%s'", user, config.Language)
+		}
+	}
+
+	for team, installation := range d.Teams {
+		if installation.ID() != team || installation.BotToken == "" {
+			return fmt.Errorf("team %s: invalid installation", team)
+		}
+	}
+
+	return nil
+}
+
+// Unscoped returns the channels and users whose configuration isn't scoped
+// to a team, as written by deployments serving a single workspace
+func (d *Document) Unscoped() []string {
+	keys := []string{}
+	for channel := range d.Channels {
+		keys = appendUnscoped(keys, channel)
+	}
+	for source := range d.Mirrors {
+		keys = appendUnscoped(keys, source)
+	}
+	for user := range d.Users {
+		keys = appendUnscoped(keys, user)
+	}
+	for _, channel := range d.Personal {
+		keys = appendUnscoped(keys, channel)
+	}
+	return keys
+}
+
+// Scope scopes the configuration of a single workspace deployment to its
+// team, so it is used once the bot serves several workspaces. Configuration
+// already scoped to the team takes precedence.
+func (d *Document) Scope(team string) {
+	for channel, config := range d.Channels {
+		if !isScoped(channel) {
+			delete(d.Channels, channel)
+			if _, ok := d.Channels[scopeKey(team, channel)]; !ok {
+				d.Channels[scopeKey(team, channel)] = config
+			}
+		}
+	}
+	for source, links := range d.Mirrors {
+		if !isScoped(source) {
+			delete(d.Mirrors, source)
+			if _, ok := d.Mirrors[scopeKey(team, source)]; !ok {
+				d.Mirrors[scopeKey(team, source)] = links
+			}
+		}
+	}
+	for user, config := range d.Users {
+		if !isScoped(user) {
+			delete(d.Users, user)
+			if _, ok := d.Users[scopeKey(team, user)]; !ok {
+				d.Users[scopeKey(team, user)] = config
+			}
+		}
+	}
+
+	personal := []string{}
+	seen := map[string]bool{}
+	for _, channel := range d.Personal {
+		if !isScoped(channel) {
+			channel = scopeKey(team, channel)
+		}
+		if !seen[channel] {
+			personal = append(personal, channel)
+			seen[channel] = true
+		}
+	}
+	d.Personal = personal
+}
+
+// document captures the current configuration of the bot
+func (b *Bot) document() *Document {
+	doc := &Document{
+		SchemaVersion: CurrentSchemaVersion,
+		WrittenAt:     time.Now().UTC(),
+		BotVersion:    b.version,
+		Channels:      map[string]ChannelConfig{},
+		Mirrors:       map[string][]MirrorLink{},
+		Users:         map[string]UserConfig{},
+		Personal:      b.preferences.PersonalChannels(),
+	}
+
+	for channel, selected := range b.detector.Snapshot() {
+		config := ChannelConfig{Languages: []string{}}
+		for _, language := range selected.Languages {
+			config.Languages = append(config.Languages, language.String())
+			if dialect := selected.Dialect(language); dialect != "" {
+				if config.Dialects == nil {
+					config.Dialects = map[string]string{}
+				}
+				config.Dialects[language.String()] = dialect
+			}
+		}
+		doc.Channels[string(channel)] = config
+	}
+
+	for _, source := range b.mirrors.Sources() {
+		doc.Mirrors[source] = b.mirrors.Get(source)
+	}
+
+	for _, user := range b.preferences.Users() {
+		doc.Users[user] = UserConfig{Language: b.preferences.Language(user)}
+	}
+
+	return doc
+}
+
+// apply loads the document's configuration into the detector, mirrors and preferences
+func (b *Bot) apply(doc *Document) error {
+	for channel, config := range doc.Channels {
+		if _, err := b.detector.UpdateSelected(channel, config.Languages...); err != nil {
+			return errors.Wrapf(err, "channel %s", channel)
+		}
+		for language, dialect := range config.Dialects {
+			if _, err := b.detector.UpdateDialect(channel, language, dialect); err != nil {
+				return errors.Wrapf(err, "channel %s", channel)
+			}
+		}
+	}
+
+	for source, links := range doc.Mirrors {
+		// Add puts the newest link first
+		for i := len(links) - 1; i >= 0; i-- {
+			b.mirrors.Add(source, links[i])
+		}
+	}
+
+	for user, config := range doc.Users {
+		b.preferences.SetLanguage(user, config.Language)
+	}
+	for _, channel := range doc.Personal {
+		b.preferences.SetPersonal(channel, true)
+	}
+
+	return nil
+}
+
+// load reads the configuration document from the datastore, migrating it to
+// the current schema version. The original of a migrated document is kept
+// under the backups namespace.
+func (b *Bot) load() error {
+	doc, version, err := ReadDocument(b.datastore)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "error loading config")
+	}
+
+	if err := b.apply(doc); err != nil {
+		return err
+	}
+	if unscoped := doc.Unscoped(); b.teams != nil && len(unscoped) > 0 {
+		b.logger.Warnf("ignoring %d channel and user settings that aren't scoped to a workspace; run `fanyi config scope -team <team ID>` to keep them", len(unscoped))
+	}
+
+	if version < CurrentSchemaVersion {
+		b.logger.Infof("migrated config from schema version %d to %d", version, CurrentSchemaVersion)
+		doc.WrittenAt = time.Now().UTC()
+		doc.BotVersion = b.version
+		return WriteDocument(b.datastore, doc)
+	}
+	return nil
+}
+
+// ReadDocument reads the configuration document from the datastore, upgraded
+// to the current schema version, and the version it was stored as. An error
+// satisfying os.IsNotExist is returned if there is no document.
+func ReadDocument(store clients.DataStore) (*Document, int, error) {
+	data, err := store.Get(datastoreKey)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	doc, version, err := ParseDocument(data)
+	if err != nil {
+		return nil, version, err
+	}
+
+	// Mirrors were kept in their own blob before versioning
+	if version == 0 {
+		legacy, err := store.Get(mirrorsKey)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, version, errors.Wrapf(err, "error retrieving mirrors from datastore")
+		}
+		if legacy != nil {
+			mirrors := NewMirrors()
+			if err := mirrors.FromJSON(legacy); err != nil {
+				return nil, version, errors.Wrapf(err, "error loading mirrors config")
+			}
+			doc.Mirrors = mirrors.Links
+			if err := doc.Validate(); err != nil {
+				return nil, version, err
+			}
+		}
+	}
+
+	return doc, version, nil
+}
+
+// WriteDocument validates the document and replaces the configuration in the
+// datastore with it. The replaced document is kept under the backups namespace;
+// replaced installations are not, as they hold bot tokens.
+func WriteDocument(store clients.DataStore, doc *Document) error {
+	if err := doc.Validate(); err != nil {
+		return err
+	}
+
+	// Installations are kept out of the document
+	stored := *doc
+	stored.Teams = nil
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+
+	installations := map[string][]byte{}
+	for team, installation := range doc.Teams {
+		if installations[team], err = json.Marshal(installation); err != nil {
+			return err
+		}
+	}
+	replaced := []string{}
+	if doc.Teams != nil {
+		existing, err := clients.ReadInstallations(store)
+		if err != nil {
+			return err
+		}
+		for team := range existing {
+			if _, ok := doc.Teams[team]; !ok {
+				replaced = append(replaced, team)
+			}
+		}
+	}
+
+	backups := map[string][]byte{}
+	for _, key := range []string{datastoreKey, mirrorsKey} {
+		previous, err := store.Get(key)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return errors.Wrapf(err, "error retrieving %s from datastore", key)
+		}
+		backups[key] = previous
+	}
+
+	version := 0
+	if previous, ok := backups[datastoreKey]; ok {
+		var header struct {
+			SchemaVersion int `json:"schema_version"`
+		}
+		_ = json.Unmarshal(previous, &header)
+		version = header.SchemaVersion
+	}
+
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	return store.Update(func(batch clients.Batch) error {
+		for key, previous := range backups {
+			batch.Set(clients.Key(backupNamespace, fmt.Sprintf("%s.v%d.%s", key, version, stamp)), previous)
+		}
+		// Mirrors are part of the document since schema version 1
+		if _, ok := backups[mirrorsKey]; ok {
+			batch.Delete(mirrorsKey)
+		}
+		batch.Set(datastoreKey, data)
+		for team, installation := range installations {
+			batch.Set(clients.InstallationKey(team), installation)
+		}
+		for _, team := range replaced {
+			batch.Delete(clients.InstallationKey(team))
+		}
+		return nil
+	})
+}
+
+// =========== Migrations ================ //
+
+// migrateV0 upgrades the unversioned serialized Detector, which stored
+// languages as lingua enum values, e.g.
+//
+//	{"select_detectors": {"C123": {"selected": {"l1": 17, "l2": 13, "d1": "Wuhan"}}}}
+func migrateV0(doc map[string]json.RawMessage) error {
+	var legacy struct {
+		SelectDetectors map[string]struct {
+			Selected *struct {
+				L1 lingua.Language `json:"l1"`
+				L2 lingua.Language `json:"l2"`
+				D1 string          `json:"d1"`
+				D2 string          `json:"d2"`
+			} `json:"selected"`
+		} `json:"select_detectors"`
+	}
+	if raw, ok := doc["select_detectors"]; ok {
+		if err := json.Unmarshal(raw, &legacy.SelectDetectors); err != nil {
+			return err
+		}
+	}
+
+	channels := map[string]ChannelConfig{}
+	for channel, selectDetector := range legacy.SelectDetectors {
+		selected := selectDetector.Selected
+		if selected == nil {
+			continue
+		}
+
+		config := ChannelConfig{Languages: []string{selected.L1.String(), selected.L2.String()}}
+		if selected.D1 != "" || selected.D2 != "" {
+			config.Dialects = map[string]string{}
+			if selected.D1 != "" {
+				config.Dialects[selected.L1.String()] = selected.D1
+			}
+			if selected.D2 != "" {
+				config.Dialects[selected.L2.String()] = selected.D2
+			}
+		}
+		channels[channel] = config
+	}
+
+	delete(doc, "select_detectors")
+	doc["channels"], _ = json.Marshal(channels)
+	doc["mirrors"], _ = json.Marshal(map[string][]MirrorLink{})
+	return nil
+}
+
+// migrateV1 adds user preferences and personal translation channels
+func migrateV1(doc map[string]json.RawMessage) error {
+	doc["users"], _ = json.Marshal(map[string]UserConfig{})
+	doc["personal"], _ = json.Marshal([]string{})
+	return nil
+}
+
+// =========== Helpers ================ //
+
+// selectedLanguage reports whether language is one of languages, ignoring
+// case and spacing. Names are compared rather than language codes, which
+// variants such as "Chinese Simplified" and "Chinese Traditional" share.
+func selectedLanguage(languages []string, language string) bool {
+	for _, l := range languages {
+		if normalizeLanguage(l) == normalizeLanguage(language) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeLanguage(language string) string {
+	return strings.ToLower(strings.Join(strings.Fields(language), " "))
+}
+
+// scopeKey prefixes the key of a channel or user with its team
+func scopeKey(team, key string) string {
+	return team + ":" + key
+}
+
+func isScoped(key string) bool {
+	return strings.Contains(key, ":")
+}
+
+func appendUnscoped(keys []string, key string) []string {
+	if isScoped(key) {
+		return keys
+	}
+	return append(keys, key)
+}
@@ -0,0 +1,212 @@
+/*
+ * File: document_test.go
+ * Project: bot
+ * File Created: Saturday, 17th October 2026 7:22:02 am
+ * Author: Mark Mester (mmester6016@gmail.com)
+ * -----
+ * Last Modified: Saturday, 17th October 2026 7:22:02 am
+ * Modified By: Mark Mester (mmester6016@gmail.com>)
+ */
+package slackbot
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/markmester/fanyi-slackbot/pkg/clients"
+)
+
+func TestParseDocument(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		version int
+		want    Document
+	}{
+		{
+			// The serialized Detector, with languages as lingua enum values
+			// (17 = English, 12 = Chinese)
+			name:    "v0",
+			data:    `{"select_detectors": {"C1": {"selected": {"l1": 17, "l2": 12, "d1": "", "d2": "Wuhan"}}, "C2": {"selected": null}}}`,
+			version: 0,
+			want: Document{
+				SchemaVersion: CurrentSchemaVersion,
+				Channels: map[string]ChannelConfig{
+					"C1": {Languages: []string{"English", "Chinese"}, Dialects: map[string]string{"Chinese": "Wuhan"}},
+				},
+				Mirrors:  map[string][]MirrorLink{},
+				Users:    map[string]UserConfig{},
+				Personal: []string{},
+			},
+		},
+		{
+			name: "v1",
+			data: `{"schema_version": 1, "written_at": "2026-01-02T03:04:05Z", "bot_version": "v1.2.0",
+				"channels": {"C1": {"languages": ["English", "Spanish"]}},
+				"mirrors": {"C1": [{"target": "C9", "language": "German"}]}}`,
+			version: 1,
+			want: Document{
+				SchemaVersion: CurrentSchemaVersion,
+				BotVersion:    "v1.2.0",
+				Channels:      map[string]ChannelConfig{"C1": {Languages: []string{"English", "Spanish"}}},
+				Mirrors:       map[string][]MirrorLink{"C1": {{Target: "C9", Language: "German"}}},
+				Users:         map[string]UserConfig{},
+				Personal:      []string{},
+			},
+		},
+		{
+			name: "current",
+			data: `{"schema_version": 2, "bot_version": "dev",
+				"channels": {"T1:C1": {"languages": ["English", "Spanish", "German"], "dialects": {"Spanish": "Mexican"}}},
+				"mirrors": {}, "users": {"T1:U1": {"language": "Japanese"}}, "personal": ["T1:C1"]}`,
+			version: 2,
+			want: Document{
+				SchemaVersion: CurrentSchemaVersion,
+				BotVersion:    "dev",
+				Channels: map[string]ChannelConfig{
+					"T1:C1": {Languages: []string{"English", "Spanish", "German"}, Dialects: map[string]string{"Spanish": "Mexican"}},
+				},
+				Mirrors:  map[string][]MirrorLink{},
+				Users:    map[string]UserConfig{"T1:U1": {Language: "Japanese"}},
+				Personal: []string{"T1:C1"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, version, err := ParseDocument([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("ParseDocument: %s", err)
+			}
+			if version != tt.version {
+				t.Errorf("version = %d, want %d", version, tt.version)
+			}
+
+			doc.WrittenAt = tt.want.WrittenAt
+			if !reflect.DeepEqual(*doc, tt.want) {
+				t.Errorf("ParseDocument = %+v, want %+v", *doc, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDocumentErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		error string
+	}{
+		{name: "future version", data: `{"schema_version": 3, "channels": {}}`, error: "newer than supported"},
+		{name: "invalid json", data: `{"schema_version": `, error: "invalid document"},
+		{name: "invalid version", data: `{"schema_version": "two"}`, error: "invalid schema version"},
+		{name: "invalid v0", data: `{"select_detectors": []}`, error: "unable to migrate document from schema version 0"},
+		{name: "one language", data: `{"schema_version": 2, "channels": {"C1": {"languages": ["English"]}}}`, error: "at least 2 languages"},
+		{name: "unknown language", data: `{"schema_version": 2, "channels": {"C1": {"languages": ["English", "Klingon"]}}}`, error: "unknown language 'Klingon'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := ParseDocument([]byte(tt.data)); err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("ParseDocument error = %v, want %q", err, tt.error)
+			}
+		})
+	}
+}
+
+func TestValidateDuplicateLanguages(t *testing.T) {
+	tests := []struct {
+		name   string
+		config ChannelConfig
+		error  string
+	}{
+		{name: "variants", config: ChannelConfig{Languages: []string{"Chinese Simplified", "Chinese Traditional"}}},
+		{name: "case and spacing", config: ChannelConfig{Languages: []string{"English", " english", "Spanish"}}, error: "language ' english' given more than once"},
+		{name: "dialect of variant", config: ChannelConfig{
+			Languages: []string{"Chinese Simplified", "English"},
+			Dialects:  map[string]string{"Chinese Traditional": "Taiwanese"},
+		}, error: "dialect given for unselected language 'Chinese Traditional'"},
+		{name: "dialect", config: ChannelConfig{
+			Languages: []string{"Chinese Simplified", "English"},
+			Dialects:  map[string]string{"chinese simplified": "Wuhan"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := &Document{SchemaVersion: CurrentSchemaVersion, Channels: map[string]ChannelConfig{"C1": tt.config}}
+			err := doc.Validate()
+			if tt.error == "" && err != nil {
+				t.Errorf("Validate: %s", err)
+			}
+			if tt.error != "" && (err == nil || !strings.Contains(err.Error(), tt.error)) {
+				t.Errorf("Validate error = %v, want %q", err, tt.error)
+			}
+		})
+	}
+}
+
+func TestReadDocumentV0(t *testing.T) {
+	store, err := clients.NewLocalClient(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalClient: %s", err)
+	}
+	legacy := map[string]string{
+		datastoreKey: `{"select_detectors": {"C1": {"selected": {"l1": 17, "l2": 12}}}}`,
+		mirrorsKey:   `{"links": {"C1": [{"target": "C9", "language": "German"}]}}`,
+	}
+	for key, data := range legacy {
+		if err := store.Set(key, []byte(data)); err != nil {
+			t.Fatalf("Set: %s", err)
+		}
+	}
+
+	doc, version, err := ReadDocument(store)
+	if err != nil {
+		t.Fatalf("ReadDocument: %s", err)
+	}
+	if version != 0 {
+		t.Errorf("version = %d, want 0", version)
+	}
+	if want := map[string][]MirrorLink{"C1": {{Target: "C9", Language: "German"}}}; !reflect.DeepEqual(doc.Mirrors, want) {
+		t.Errorf("Mirrors = %+v, want %+v", doc.Mirrors, want)
+	}
+
+	if err := WriteDocument(store, doc); err != nil {
+		t.Fatalf("WriteDocument: %s", err)
+	}
+
+	// The legacy blobs are kept as backups, and mirrors are only in the document
+	backups, err := store.List(backupNamespace + clients.KeySeparator)
+	if err != nil {
+		t.Fatalf("List: %s", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("backups = %q, want one of each legacy blob", backups)
+	}
+	for _, backup := range backups {
+		name := strings.TrimPrefix(backup, backupNamespace+clients.KeySeparator)
+		key := name[:strings.Index(name, ".v0.")]
+		data, err := store.Get(backup)
+		if err != nil || string(data) != legacy[key] {
+			t.Errorf("backup %s = %q (%v), want %q", backup, data, err, legacy[key])
+		}
+	}
+	if _, err := store.Get(mirrorsKey); err == nil {
+		t.Errorf("%s was not removed", mirrorsKey)
+	}
+
+	data, err := store.Get(datastoreKey)
+	if err != nil {
+		t.Fatalf("Get: %s", err)
+	}
+	var stored Document
+	if err := json.Unmarshal(data, &stored); err != nil {
+		t.Fatalf("invalid stored document: %s", err)
+	}
+	if stored.SchemaVersion != CurrentSchemaVersion || !reflect.DeepEqual(stored.Mirrors, doc.Mirrors) {
+		t.Errorf("stored document = %+v", stored)
+	}
+}
@@ -0,0 +1,343 @@
+/*
+ * File: feedback.go
+ * Project: bot
+ * File Created: Saturday, 17th October 2026 6:23:08 am
+ * Author: Mark Mester (mmester6016@gmail.com)
+ * -----
+ * Last Modified: Saturday, 17th October 2026 7:02:38 am
+ * Modified By: Mark Mester (mmester6016@gmail.com>)
+ */
+package slackbot
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/slack-go/slack"
+
+	"github.com/markmester/fanyi-slackbot/pkg/clients"
+)
+
+const (
+	actionRetry   = "translation-retry"
+	actionLiteral = "translation-literal"
+	actionExplain = "translation-explain"
+
+	// Callback and block IDs of the retry modal
+	callbackRetry   = "translation-retry-modal"
+	blockComplaint  = "translation-complaint"
+	actionComplaint = "translation-complaint-input"
+
+	// maxSectionLength is the most text a section block may hold
+	maxSectionLength = 3000
+
+	literalFeedback = "The translation is too loose. Rephrase it more literally, staying close to the original wording and sentence structure."
+)
+
+// feedbackRef identifies the translation a feedback action applies to; it
+// is carried in the value of each feedback button
+type feedbackRef struct {
+	Timestamp      string `json:"ts"`
+	SourceLanguage string `json:"f"`
+	SourceDialect  string `json:"fd,omitempty"`
+	TargetLanguage string `json:"t"`
+	TargetDialect  string `json:"td,omitempty"`
+}
+
+// retryMetadata is carried through the retry modal as its private metadata,
+// which is limited to 3000 characters; the rejected translation is looked up
+// again when the modal is submitted
+type retryMetadata struct {
+	Ref     feedbackRef `json:"ref"`
+	Channel string      `json:"c"`
+	Reply   string      `json:"r"`
+	Thread  string      `json:"th"`
+}
+
+// translationSection is one translation of a translation message
+type translationSection struct {
+	Ref  feedbackRef
+	Body string
+}
+
+// heading names the section's language, e.g. "Chinese (Wuhan)"
+func (s translationSection) heading() string {
+	if s.Ref.TargetDialect != "" {
+		return fmt.Sprintf("%s (%s)", s.Ref.TargetLanguage, s.Ref.TargetDialect)
+	}
+	return s.Ref.TargetLanguage
+}
+
+// translationMessage returns the options posting one or more translations,
+// each with buttons letting readers ask for a better translation or an
+// explanation. Translations into several languages are headed by their language.
+func translationMessage(sections ...translationSection) []slack.MsgOption {
+	blocks := []slack.Block{}
+	texts := []string{}
+	for i, section := range sections {
+		body := section.Body
+		blockID := "translation-feedback"
+		if len(sections) > 1 {
+			body = fmt.Sprintf("*%s*\n%s", section.heading(), body)
+			blockID = fmt.Sprintf("translation-feedback-%d", i)
+		}
+		texts = append(texts, body)
+
+		value, _ := json.Marshal(section.Ref)
+		for _, chunk := range chunkText(body, maxSectionLength) {
+			blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, chunk, false, false), nil, nil))
+		}
+		blocks = append(blocks, slack.NewActionBlock(blockID,
+			slack.NewButtonBlockElement(actionRetry, string(value), slack.NewTextBlockObject(slack.PlainTextType, "Retry", true, false)),
+			slack.NewButtonBlockElement(actionLiteral, string(value), slack.NewTextBlockObject(slack.PlainTextType, "Rephrase more literally", true, false)),
+			slack.NewButtonBlockElement(actionExplain, string(value), slack.NewTextBlockObject(slack.PlainTextType, "Explain", true, false)),
+		))
+	}
+
+	return []slack.MsgOption{
+		slack.MsgOptionText(strings.Join(texts, "\n\n"), false),
+		slack.MsgOptionBlocks(blocks...),
+	}
+}
+
+// handleFeedbackAction handles a click on one of a translation's feedback buttons
+func (b *Bot) handleFeedbackAction(interaction slack.InteractionCallback, action *slack.BlockAction) error {
+	var ref feedbackRef
+	if err := json.Unmarshal([]byte(action.Value), &ref); err != nil {
+		return fmt.Errorf("invalid feedback reference: %s", err.Error())
+	}
+
+	channel := interaction.Channel.ID
+	reply := interaction.Message.Timestamp
+	thread := interaction.Message.ThreadTimestamp
+
+	switch action.ActionID {
+	case actionRetry:
+		metadata, _ := json.Marshal(retryMetadata{Ref: ref, Channel: channel, Reply: reply, Thread: thread})
+		return b.slack.OpenView(interaction.TriggerID, slack.ModalViewRequest{
+			Type:            slack.VTModal,
+			CallbackID:      callbackRetry,
+			PrivateMetadata: string(metadata),
+			Title:           slack.NewTextBlockObject(slack.PlainTextType, "Retry translation", false, false),
+			Submit:          slack.NewTextBlockObject(slack.PlainTextType, "Retry", false, false),
+			Close:           slack.NewTextBlockObject(slack.PlainTextType, "Cancel", false, false),
+			Blocks: slack.Blocks{BlockSet: []slack.Block{
+				slack.NewInputBlock(blockComplaint,
+					slack.NewTextBlockObject(slack.PlainTextType, "What's wrong with this translation?", false, false),
+					slack.NewTextBlockObject(slack.PlainTextType, "e.g. the tone is too formal, or a word was misunderstood", false, false),
+					slack.NewPlainTextInputBlockElement(
+						slack.NewTextBlockObject(slack.PlainTextType, "Describe the problem", false, false),
+						actionComplaint,
+					),
+				),
+			}},
+		})
+
+	case actionLiteral:
+		return b.retryTranslation(interaction.User.ID, channel, reply, thread, literalFeedback, ref)
+
+	case actionExplain:
+		previous := sectionBody(translationSections(interaction.Message.Blocks), ref.TargetLanguage)
+		if tracked, ok := b.findReply(channel, ref.Timestamp, reply); ok {
+			previous = sectionBody(tracked.sections(ref.Timestamp), ref.TargetLanguage)
+		}
+		if previous == "" {
+			previous = interaction.Message.Text
+		}
+		return b.explainTranslation(interaction.User.ID, channel, thread, previous, ref)
+	}
+
+	return nil
+}
+
+// handleRetrySubmission handles the submission of the retry modal
+func (b *Bot) handleRetrySubmission(interaction slack.InteractionCallback) error {
+	var metadata retryMetadata
+	if err := json.Unmarshal([]byte(interaction.View.PrivateMetadata), &metadata); err != nil {
+		return fmt.Errorf("invalid retry metadata: %s", err.Error())
+	}
+
+	complaint := ""
+	if interaction.View.State != nil {
+		complaint = interaction.View.State.Values[blockComplaint][actionComplaint].Value
+	}
+
+	return b.retryTranslation(interaction.User.ID, metadata.Channel, metadata.Reply, metadata.Thread, complaint, metadata.Ref)
+}
+
+// retryTranslation translates the source message again, telling the
+// translator what was wrong with the previous attempt, and replaces the
+// previous translation with the new one
+func (b *Bot) retryTranslation(user, channel, reply, thread, complaint string, ref feedbackRef) error {
+	// Keep the reply's other translations; replies no longer tracked are
+	// rebuilt from the posted message
+	tracked, ok := b.findReply(channel, ref.Timestamp, reply)
+	var sections []translationSection
+	if ok {
+		sections = tracked.sections(ref.Timestamp)
+	} else {
+		posted, err := b.slack.GetMessage(channel, reply)
+		if err != nil {
+			b.logger.Errorf("unable to get translation msg ts=%s; err=%s", reply, err.Error())
+			b.postErrorMessage(channel, user, thread)
+			return err
+		}
+		sections = translationSections(posted.Blocks)
+		if !hasSection(sections, ref.TargetLanguage) {
+			return b.slack.PostEphemeralMessage(channel, user,
+				slack.MsgOptionText("Sorry, this translation can no longer be retried.", false),
+				slack.MsgOptionTS(thread))
+		}
+	}
+	previous := sectionBody(sections, ref.TargetLanguage)
+
+	msg, err := b.slack.GetMessage(channel, ref.Timestamp)
+	if err != nil {
+		b.logger.Errorf("unable to get source msg of translation; err=%s", err.Error())
+		b.postErrorMessage(channel, user, thread)
+		return err
+	}
+
+	b.logger.Infof("retrying translation of msg=%s from %s->%s; feedback=%s", msg.Text, ref.SourceLanguage, ref.TargetLanguage, complaint)
+
+	body, err := b.translate(b.ctx, ref.SourceLanguage, ref.SourceDialect, ref.TargetLanguage, ref.TargetDialect, msg.Text,
+		clients.WithFeedback(previous, complaint))
+	if err != nil {
+		b.logger.Errorf("unable to retry translation for msg=%s from %s->%s; err=%s", msg.Text, ref.SourceLanguage, ref.TargetLanguage, err.Error())
+		b.postErrorMessage(channel, user, thread)
+		return err
+	}
+
+	var options []slack.MsgOption
+	if ok {
+		tracked = tracked.withBody(ref.TargetLanguage, body)
+		options = tracked.message(ref.Timestamp)
+	} else {
+		for i := range sections {
+			if sections[i].Ref.TargetLanguage == ref.TargetLanguage {
+				sections[i].Body = body
+			}
+		}
+		options = translationMessage(sections...)
+	}
+
+	if err := b.slack.UpdateMessage(channel, reply, options...); err != nil {
+		b.logger.Errorf("unable to update translation for msg=%s; err=%s", msg.Text, err.Error())
+		b.postErrorMessage(channel, user, thread)
+		return err
+	}
+	if ok {
+		b.updateReply(channel, ref.Timestamp, tracked)
+	}
+	return nil
+}
+
+// explainTranslation privately explains a translation to the user who asked
+func (b *Bot) explainTranslation(user, channel, thread, translation string, ref feedbackRef) error {
+	explainer, ok := b.translator.(clients.Explainer)
+	if !ok {
+		return b.slack.PostEphemeralMessage(channel, user,
+			slack.MsgOptionText("Sorry, the current translation engine is unable to explain translations.", false),
+			slack.MsgOptionTS(thread))
+	}
+
+	msg, err := b.slack.GetMessage(channel, ref.Timestamp)
+	if err != nil {
+		b.logger.Errorf("unable to get source msg of translation; err=%s", err.Error())
+		b.postErrorMessage(channel, user, thread)
+		return err
+	}
+
+	explanation, err := explainer.Explain(b.ctx, ref.SourceLanguage, ref.TargetLanguage, msg.Text, translation)
+	if err != nil {
+		b.logger.Errorf("unable to explain translation of msg=%s; err=%s", msg.Text, err.Error())
+		b.postErrorMessage(channel, user, thread)
+		return err
+	}
+
+	return b.slack.PostEphemeralMessage(channel, user,
+		slack.MsgOptionText(explanation, false),
+		slack.MsgOptionTS(thread))
+}
+
+// translationSections recovers the translations of a message posted with
+// translationMessage from its blocks
+func translationSections(blocks slack.Blocks) []translationSection {
+	sections := []translationSection{}
+	body := ""
+	for _, block := range blocks.BlockSet {
+		switch block := block.(type) {
+		case *slack.SectionBlock:
+			if block.Text != nil {
+				body += block.Text.Text
+			}
+		case *slack.ActionBlock:
+			if !strings.HasPrefix(block.BlockID, "translation-feedback") || block.Elements == nil {
+				continue
+			}
+			for _, element := range block.Elements.ElementSet {
+				button, ok := element.(*slack.ButtonBlockElement)
+				if !ok {
+					continue
+				}
+				var ref feedbackRef
+				if err := json.Unmarshal([]byte(button.Value), &ref); err == nil {
+					sections = append(sections, translationSection{Ref: ref, Body: body})
+				}
+				break
+			}
+			body = ""
+		}
+	}
+
+	// Translations into several languages are headed by their language
+	if len(sections) > 1 {
+		for i := range sections {
+			sections[i].Body = strings.TrimPrefix(sections[i].Body, fmt.Sprintf("*%s*\n", sections[i].heading()))
+		}
+	}
+	return sections
+}
+
+// hasSection reports whether one of sections translates into language
+func hasSection(sections []translationSection, language string) bool {
+	for _, section := range sections {
+		if section.Ref.TargetLanguage == language {
+			return true
+		}
+	}
+	return false
+}
+
+// sectionBody returns the translation into language held by sections, if any
+func sectionBody(sections []translationSection, language string) string {
+	for _, section := range sections {
+		if section.Ref.TargetLanguage == language {
+			return section.Body
+		}
+	}
+	return ""
+}
+
+// chunkText splits text into pieces of at most size bytes, preferring to
+// break at newlines and never splitting a multi-byte character
+func chunkText(text string, size int) []string {
+	chunks := []string{}
+	for len(text) > size {
+		cut := size
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		for i := cut - 1; i > size/2; i-- {
+			if text[i] == '\n' {
+				cut = i + 1
+				break
+			}
+		}
+		chunks = append(chunks, text[:cut])
+		text = text[cut:]
+	}
+	return append(chunks, text)
+}
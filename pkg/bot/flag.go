@@ -4,7 +4,7 @@
  * File Created: Wednesday, 25th January 2023 2:47:57 pm
  * Author: Mark Mester (mmester6016@gmail.com)
  * -----
- * Last Modified: Wednesday, 25th January 2023 9:42:50 pm
+ * Last Modified: Saturday, 17th October 2026 6:18:20 am
  * Modified By: Mark Mester (mmester6016@gmail.com>)
  */
 package slackbot
@@ -271,6 +271,28 @@ var flagMap = map[string]string{
 	"flag-eu": "EU",
 	"flag-aq": "Antarctica",
 }
+
+// flag emoji to dialect of the flag's language, for countries whose
+// variant of a language differs noticeably from the standard
+var flagDialectMap = map[string]string{
+	"flag-ar": "Rioplatense",
+	"flag-at": "Austrian",
+	"flag-au": "Australian",
+	"flag-be": "Flemish",
+	"flag-br": "Brazilian",
+	"flag-ca": "Canadian",
+	"flag-ch": "Swiss",
+	"flag-gb": "British",
+	"flag-hk": "Cantonese",
+	"flag-ie": "Irish",
+	"flag-mo": "Cantonese",
+	"flag-mx": "Mexican",
+	"flag-nz": "New Zealand",
+	"flag-pt": "European",
+	"flag-tw": "Taiwanese Mandarin",
+	"flag-us": "American",
+}
+
 var alternateFlagMap = func() map[string]string {
 	alternateFlagMap := map[string]string{}
 	for k, v := range flagMap {
@@ -279,6 +301,14 @@ var alternateFlagMap = func() map[string]string {
 	return alternateFlagMap
 }()
 
+var alternateFlagDialectMap = func() map[string]string {
+	alternateFlagDialectMap := map[string]string{}
+	for k, v := range flagDialectMap {
+		alternateFlagDialectMap[strings.TrimPrefix(k, "flag-")] = v
+	}
+	return alternateFlagDialectMap
+}()
+
 // GetLanguageCode is
 func GetLanguageCode(flag string) (code string, ok bool) {
 	code, ok = flagMap[flag]
@@ -287,3 +317,11 @@ func GetLanguageCode(flag string) (code string, ok bool) {
 	}
 	return
 }
+
+// GetDialect returns the dialect associated with a flag, if any
+func GetDialect(flag string) string {
+	if dialect, ok := flagDialectMap[flag]; ok {
+		return dialect
+	}
+	return alternateFlagDialectMap[flag]
+}
@@ -0,0 +1,160 @@
+/*
+ * File: history.go
+ * Project: bot
+ * File Created: Saturday, 17th October 2026 6:24:23 am
+ * Author: Mark Mester (mmester6016@gmail.com)
+ * -----
+ * Last Modified: Saturday, 17th October 2026 6:56:30 am
+ * Modified By: Mark Mester (mmester6016@gmail.com>)
+ */
+package slackbot
+
+import (
+	"fmt"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/slack-go/slack/slackevents"
+)
+
+const (
+	// DefaultHistoryMessages is the number of preceding messages given as context
+	DefaultHistoryMessages = 10
+	// DefaultHistoryTokens bounds the (estimated) tokens of context per translation
+	DefaultHistoryTokens = 500
+)
+
+// historyMessage is a message of a conversation used as translation context
+type historyMessage struct {
+	User      string
+	Text      string
+	Timestamp string
+}
+
+// historyWindow caches the most recent messages of a channel or thread so
+// a busy conversation doesn't cost one history call per translation
+type historyWindow struct {
+	mu sync.Mutex
+
+	// since is the timestamp the window was fetched before; messages older
+	// than it may be missing from the window
+	since    string
+	messages []historyMessage
+}
+
+func historyKey(channel, thread string) string {
+	return channel + ":" + thread
+}
+
+// before returns the messages of the window preceding timestamp, oldest first
+func (w *historyWindow) before(timestamp string) []historyMessage {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	msgs := []historyMessage{}
+	for _, msg := range w.messages {
+		if msg.Timestamp < timestamp {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs
+}
+
+func (w *historyWindow) append(msg historyMessage, limit int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.messages = append(w.messages, msg)
+	if len(w.messages) > limit {
+		w.messages = w.messages[len(w.messages)-limit:]
+	}
+}
+
+// recordHistory adds a new message to its conversation's window, if cached
+func (b *Bot) recordHistory(ev *slackevents.MessageEvent) {
+	if b.historyMessages == 0 {
+		return
+	}
+
+	window, found := b.history.Get(historyKey(ev.Channel, ev.ThreadTimeStamp))
+	if !found {
+		return
+	}
+	window.(*historyWindow).append(historyMessage{
+		User:      ev.User,
+		Text:      ev.Text,
+		Timestamp: ev.TimeStamp,
+	}, b.historyMessages)
+}
+
+// conversationContext returns the messages preceding the message at
+// timestamp, formatted for the translator and trimmed to the token budget.
+// Use messageContext for new messages.
+func (b *Bot) conversationContext(channel, thread, timestamp string) []string {
+	return b.historyContext(channel, thread, timestamp, nil)
+}
+
+// messageContext returns the messages preceding a new message, formatted for
+// the translator and trimmed to the token budget
+func (b *Bot) messageContext(ev *slackevents.MessageEvent) []string {
+	return b.historyContext(ev.Channel, ev.ThreadTimeStamp, ev.TimeStamp, &historyMessage{
+		User:      ev.User,
+		Text:      ev.Text,
+		Timestamp: ev.TimeStamp,
+	})
+}
+
+// historyContext returns the context of the message at timestamp. head is
+// the message itself if it is the newest of its conversation; the window
+// fetched for it is then cached for the messages following it.
+func (b *Bot) historyContext(channel, thread, timestamp string, head *historyMessage) []string {
+	if b.historyMessages == 0 {
+		return nil
+	}
+
+	var msgs []historyMessage
+	key := historyKey(channel, thread)
+	if cached, found := b.history.Get(key); found && cached.(*historyWindow).since < timestamp {
+		msgs = cached.(*historyWindow).before(timestamp)
+	} else {
+		history, err := b.slack.GetHistory(channel, thread, timestamp, b.historyMessages)
+		if err != nil {
+			b.logger.Errorf("unable to get history of channel=%s thread=%s; err=%s", channel, thread, err.Error())
+			return nil
+		}
+
+		window := &historyWindow{since: timestamp}
+		for _, msg := range history {
+			if msg.BotID != "" || msg.Text == "" {
+				continue
+			}
+			window.messages = append(window.messages, historyMessage{User: msg.User, Text: msg.Text, Timestamp: msg.Timestamp})
+		}
+		msgs = append([]historyMessage{}, window.messages...)
+
+		// Only cache windows at the head of the conversation, which new
+		// messages extend; recordHistory missed the head as there was no window
+		if head != nil && !found {
+			window.append(*head, b.historyMessages)
+			b.history.Set(key, window, cache.DefaultExpiration)
+		}
+	}
+
+	// Keep the most recent messages that fit the budget
+	lines := []string{}
+	tokens := 0
+	for i := len(msgs) - 1; i >= 0 && len(lines) < b.historyMessages; i-- {
+		line := fmt.Sprintf("%s: %s", msgs[i].User, msgs[i].Text)
+		if tokens += estimateTokens(line); tokens > b.historyTokens {
+			break
+		}
+		lines = append([]string{line}, lines...)
+	}
+	return lines
+}
+
+// estimateTokens roughly estimates the number of tokens a model needs for text
+func estimateTokens(text string) int {
+	return utf8.RuneCountInString(text)/4 + 1
+}
@@ -0,0 +1,109 @@
+/*
+ * File: markup.go
+ * Project: bot
+ * File Created: Saturday, 17th October 2026 6:17:23 am
+ * Author: Mark Mester (mmester6016@gmail.com)
+ * -----
+ * Last Modified: Saturday, 17th October 2026 6:23:08 am
+ * Modified By: Mark Mester (mmester6016@gmail.com>)
+ */
+package slackbot
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// markupPattern matches the slack mrkdwn entities that must survive translation
+// untouched: code blocks, inline code, <...> entities (mentions, channels,
+// links, special mentions) and :emoji: (including skin tones)
+var markupPattern = regexp.MustCompile("(?s)```.*?```|`[^`\n]+`|<[^<>\\s][^<>]*>|:[a-z0-9_+'-]*[a-z_+][a-z0-9_+'-]*:(?::skin-tone-[2-6]:)?")
+
+// placeholderPattern matches the opaque placeholders substituted for entities
+var placeholderPattern = regexp.MustCompile(`\{\{\d+\}\}`)
+
+// markup is a message with its slack entities replaced by opaque placeholders
+type markup struct {
+	// text with every entity replaced by its placeholder
+	text string
+	// entities in order of appearance; entity i is replaced by placeholder(i)
+	entities []string
+	// segments alternates between the plain text and entities of the message
+	segments []markupSegment
+}
+
+type markupSegment struct {
+	text     string
+	isEntity bool
+}
+
+func placeholder(i int) string {
+	return fmt.Sprintf("{{%d}}", i)
+}
+
+// tokenizeMarkup replaces the slack entities in text with placeholders.
+// Text that already contains placeholder-like sequences is left as a single
+// segment without entities so it can't be confused on restore.
+func tokenizeMarkup(text string) *markup {
+	m := &markup{text: text}
+	if placeholderPattern.MatchString(text) {
+		m.segments = []markupSegment{{text: text}}
+		return m
+	}
+
+	var sb strings.Builder
+	last := 0
+	for _, loc := range markupPattern.FindAllStringIndex(text, -1) {
+		if loc[0] > last {
+			m.segments = append(m.segments, markupSegment{text: text[last:loc[0]]})
+		}
+		entity := text[loc[0]:loc[1]]
+		m.segments = append(m.segments, markupSegment{text: entity, isEntity: true})
+
+		sb.WriteString(text[last:loc[0]])
+		sb.WriteString(placeholder(len(m.entities)))
+		m.entities = append(m.entities, entity)
+		last = loc[1]
+	}
+	if last < len(text) {
+		m.segments = append(m.segments, markupSegment{text: text[last:]})
+	}
+	sb.WriteString(text[last:])
+
+	m.text = sb.String()
+	return m
+}
+
+// restore puts the original entities back into a translation of m.text.
+// It returns false if any placeholder was dropped, duplicated or invented.
+func (m *markup) restore(translated string) (string, bool) {
+	if len(placeholderPattern.FindAllString(translated, -1)) != len(m.entities) {
+		return "", false
+	}
+
+	for i, entity := range m.entities {
+		if strings.Count(translated, placeholder(i)) != 1 {
+			return "", false
+		}
+		translated = strings.Replace(translated, placeholder(i), entity, 1)
+	}
+	return translated, true
+}
+
+// conceal replaces the entities of m appearing in text with their
+// placeholders, e.g. in an earlier translation of the same message
+func (m *markup) conceal(text string) string {
+	for i, entity := range m.entities {
+		text = strings.ReplaceAll(text, entity, placeholder(i))
+	}
+	return text
+}
+
+// hasWords reports whether s contains anything worth translating
+func hasWords(s string) bool {
+	return strings.IndexFunc(s, func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsNumber(r)
+	}) >= 0
+}
@@ -0,0 +1,152 @@
+/*
+ * File: markup_test.go
+ * Project: bot
+ * File Created: Saturday, 17th October 2026 7:23:35 am
+ * Author: Mark Mester (mmester6016@gmail.com)
+ * -----
+ * Last Modified: Saturday, 17th October 2026 7:23:35 am
+ * Modified By: Mark Mester (mmester6016@gmail.com>)
+ */
+package slackbot
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/markmester/fanyi-slackbot/pkg/clients"
+)
+
+func TestTokenizeMarkup(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		want     string
+		entities []string
+	}{
+		{name: "plain", text: "Where is the station?", want: "Where is the station?"},
+		{name: "mention", text: "Thanks <@U0123>!", want: "Thanks {{0}}!", entities: []string{"<@U0123>"}},
+		{name: "special mention", text: "<!here> lunch is ready", want: "{{0}} lunch is ready", entities: []string{"<!here>"}},
+		{name: "channel link", text: "See <#C0123|general> and <#C0456>", want: "See {{0}} and {{1}}", entities: []string{"<#C0123|general>", "<#C0456>"}},
+		{name: "url", text: "Read <https://example.com/a?b=c|the docs> first", want: "Read {{0}} first", entities: []string{"<https://example.com/a?b=c|the docs>"}},
+		{name: "emoji", text: "Great job :tada: :+1::skin-tone-3:", want: "Great job {{0}} {{1}}", entities: []string{":tada:", ":+1::skin-tone-3:"}},
+		{name: "not emoji", text: "Meet at 10:30:00", want: "Meet at 10:30:00"},
+		{name: "inline code", text: "Run `make build` now", want: "Run {{0}} now", entities: []string{"`make build`"}},
+		{name: "code block", text: "Try:\n```\nx := <-ch\n```\nthanks", want: "Try:\n{{0}}\nthanks", entities: []string{"```\nx := <-ch\n```"}},
+		{name: "comparison", text: "if a < b and c > d", want: "if a < b and c > d"},
+		{name: "placeholder in text", text: "Use {{0}} for <@U0123>", want: "Use {{0}} for <@U0123>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := tokenizeMarkup(tt.text)
+			if m.text != tt.want {
+				t.Errorf("text = %q, want %q", m.text, tt.want)
+			}
+			if !reflect.DeepEqual(m.entities, tt.entities) {
+				t.Errorf("entities = %q, want %q", m.entities, tt.entities)
+			}
+
+			// The segments always make up the original message
+			var sb strings.Builder
+			for _, segment := range m.segments {
+				sb.WriteString(segment.text)
+			}
+			if sb.String() != tt.text {
+				t.Errorf("segments = %q, want %q", sb.String(), tt.text)
+			}
+		})
+	}
+}
+
+func TestMarkupRestore(t *testing.T) {
+	m := tokenizeMarkup("Thanks <@U0123>, see <#C0123|general> :tada:")
+	if m.text != "Thanks {{0}}, see {{1}} {{2}}" {
+		t.Fatalf("text = %q", m.text)
+	}
+
+	tests := []struct {
+		name       string
+		translated string
+		want       string
+		ok         bool
+	}{
+		{name: "in order", translated: "Gracias {{0}}, mira {{1}} {{2}}", want: "Gracias <@U0123>, mira <#C0123|general> :tada:", ok: true},
+		{name: "reordered", translated: "{{2}} {{1}} を見て、{{0}} ありがとう", want: ":tada: <#C0123|general> を見て、<@U0123> ありがとう", ok: true},
+		{name: "missing", translated: "Gracias {{0}}, mira {{1}}", ok: false},
+		{name: "duplicated", translated: "Gracias {{0}} {{0}}, mira {{1}} {{2}}", ok: false},
+		{name: "duplicated instead of another", translated: "Gracias {{0}}, mira {{1}} {{1}}", ok: false},
+		{name: "invented", translated: "Gracias {{0}}, mira {{1}} {{2}} {{3}}", ok: false},
+		{name: "mangled", translated: "Gracias {0}, mira {{1}} {{2}}", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restored, ok := m.restore(tt.translated)
+			if ok != tt.ok || restored != tt.want {
+				t.Errorf("restore(%q) = %q, %t; want %q, %t", tt.translated, restored, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestMarkupConceal(t *testing.T) {
+	m := tokenizeMarkup("Thanks <@U0123> :tada:")
+	if got := m.conceal("Gracias <@U0123> :tada: :tada:"); got != "Gracias {{0}} {{1}} {{1}}" {
+		t.Errorf("conceal = %q", got)
+	}
+}
+
+// markupTranslator "translates" by upper casing, dropping any placeholders
+// when dropPlaceholders is set
+type markupTranslator struct {
+	dropPlaceholders bool
+	calls            []string
+}
+
+func (tr *markupTranslator) Translate(ctx context.Context, fromLanguage, fromDialect, toLanguage, toDialect, msg string, opts ...clients.TranslateOption) (string, error) {
+	tr.calls = append(tr.calls, msg)
+	if tr.dropPlaceholders {
+		msg = placeholderPattern.ReplaceAllString(msg, "")
+	}
+	return " " + strings.ToUpper(msg) + " ", nil
+}
+
+func TestTranslateMarkupFallback(t *testing.T) {
+	tests := []struct {
+		name             string
+		msg              string
+		dropPlaceholders bool
+		want             string
+		calls            []string
+	}{
+		{name: "no markup", msg: "hello there", want: " HELLO THERE ", calls: []string{"hello there"}},
+		{name: "placeholders kept", msg: "hi <@U0123> :wave:", want: " HI <@U0123> :wave: ", calls: []string{"hi {{0}} {{1}}"}},
+		{
+			name: "placeholders dropped", msg: "hi <@U0123>, see `make build`\n:wave: bye", dropPlaceholders: true,
+			want:  "HI <@U0123>, SEE `make build`\n:wave: BYE",
+			calls: []string{"hi {{0}}, see {{1}}\n{{2}} bye", "hi ", ", see ", " bye"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			translator := &markupTranslator{dropPlaceholders: tt.dropPlaceholders}
+			b := &Bot{translator: translator, logger: zap.NewNop().Sugar()}
+
+			translated, err := b.translate(context.Background(), "English", "", "Shouting", "", tt.msg)
+			if err != nil {
+				t.Fatalf("translate: %s", err)
+			}
+			if translated != tt.want {
+				t.Errorf("translate = %q, want %q", translated, tt.want)
+			}
+			if !reflect.DeepEqual(translator.calls, tt.calls) {
+				t.Errorf("translator calls = %q, want %q", translator.calls, tt.calls)
+			}
+		})
+	}
+}
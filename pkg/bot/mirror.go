@@ -0,0 +1,326 @@
+/*
+ * File: mirror.go
+ * Project: bot
+ * File Created: Saturday, 17th October 2026 6:21:09 am
+ * Author: Mark Mester (mmester6016@gmail.com)
+ * -----
+ * Last Modified: Saturday, 17th October 2026 6:46:03 am
+ * Modified By: Mark Mester (mmester6016@gmail.com>)
+ */
+package slackbot
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+
+	"github.com/markmester/fanyi-slackbot/pkg/clients"
+)
+
+// channelPattern matches an escaped channel reference, e.g. <#C123|general>
+var channelPattern = regexp.MustCompile(`^<#([A-Z0-9]+)(?:\|[^>]*)?>$`)
+
+// MirrorLink translates every message of a source channel into Language
+// and reposts it in the Target channel
+type MirrorLink struct {
+	Target   string `json:"target"`
+	Language string `json:"language"`
+}
+
+// Mirrors maps source channels to the channels they are mirrored into
+type Mirrors struct {
+	mu sync.RWMutex
+
+	Links map[string][]MirrorLink `json:"links"`
+}
+
+// mirroredMessage is the source of a message the bot posted in a mirror
+// channel; replies to it in the mirror are translated back to the source
+type mirroredMessage struct {
+	SourceChannel   string
+	SourceTimestamp string
+	SourceLanguage  string
+}
+
+func NewMirrors() *Mirrors {
+	return &Mirrors{Links: map[string][]MirrorLink{}}
+}
+
+func (m *Mirrors) ToJSON() ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return json.Marshal(m)
+}
+
+func (m *Mirrors) FromJSON(jsonBytes []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := json.Unmarshal(jsonBytes, m); err != nil {
+		return err
+	}
+	if m.Links == nil {
+		m.Links = map[string][]MirrorLink{}
+	}
+	return nil
+}
+
+// Get returns the links mirroring the source channel
+func (m *Mirrors) Get(source string) []MirrorLink {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return append([]MirrorLink{}, m.Links[source]...)
+}
+
+// Sources returns the mirrored channels
+func (m *Mirrors) Sources() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	sources := []string{}
+	for source := range m.Links {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+// Add mirrors source into target, replacing any existing link between them
+func (m *Mirrors) Add(source string, link MirrorLink) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	links := []MirrorLink{link}
+	for _, l := range m.Links[source] {
+		if l.Target != link.Target {
+			links = append(links, l)
+		}
+	}
+	m.Links[source] = links
+}
+
+// Remove stops mirroring source into target, or into all targets if target is empty
+func (m *Mirrors) Remove(source, target string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	links := []MirrorLink{}
+	for _, l := range m.Links[source] {
+		if target != "" && l.Target != target {
+			links = append(links, l)
+		}
+	}
+
+	removed := len(links) != len(m.Links[source])
+	if len(links) == 0 {
+		delete(m.Links, source)
+	} else {
+		m.Links[source] = links
+	}
+	return removed
+}
+
+func mirrorKey(channel, timestamp string) string {
+	return channel + ":" + timestamp
+}
+
+// handleMirrorCommand handles `/translate mirror #target <language>` and
+// `/translate mirror stop [#target]`
+func (b *Bot) handleMirrorCommand(command slack.SlashCommand, args []string) error {
+	usage := "Usage: `/translate mirror #channel <language>` or `/translate mirror stop [#channel]`"
+
+	if len(args) > 0 && args[0] == "stop" {
+		target := ""
+		if len(args) > 1 {
+			var err error
+			if target, err = b.resolveChannel(args[1]); err != nil {
+				return b.postEphemeral(command, err.Error())
+			}
+		}
+		if !b.mirrors.Remove(b.scope(command.ChannelID), target) {
+			return b.postEphemeral(command, "This channel is not being mirrored there.")
+		}
+
+		b.logger.Infof("stopped mirroring channel=%s target=%s", command.ChannelID, target)
+		b.persist()
+		if _, err := b.slack.PostMessage(command.ChannelID,
+			slack.MsgOptionText("Stopping channel mirroring!", false)); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	if len(args) < 2 {
+		return b.postEphemeral(command, usage)
+	}
+
+	target, err := b.resolveChannel(args[0])
+	if err != nil {
+		return b.postEphemeral(command, err.Error())
+	}
+	if target == command.ChannelID {
+		return b.postEphemeral(command, "A channel can't be mirrored into itself.")
+	}
+
+	language := strings.Join(args[1:], " ")
+	if clients.LanguageCode(language) == "" {
+		return b.postEphemeral(command, fmt.Sprintf("Sorry, '%s' is not a supported language.", language))
+	}
+
+	b.mirrors.Add(b.scope(command.ChannelID), MirrorLink{Target: target, Language: language})
+	b.logger.Infof("mirroring channel=%s into target=%s (%s)", command.ChannelID, target, language)
+	b.persist()
+
+	if _, err := b.slack.PostMessage(command.ChannelID,
+		slack.MsgOptionText(fmt.Sprintf("Mirroring this channel into <#%s> in %s.", target, language), false)); err != nil {
+		return err
+	}
+	if _, err := b.slack.PostMessage(target,
+		slack.MsgOptionText(fmt.Sprintf("This channel now mirrors <#%s> in %s. Reply in a thread to answer in the original channel.", command.ChannelID, language), false)); err != nil {
+		b.logger.Errorf("unable to post to mirror channel=%s; is the bot a member? err=%s", target, err.Error())
+	}
+	return nil
+}
+
+// resolveChannel returns the channel ID referenced as <#C123|name> or #name
+func (b *Bot) resolveChannel(ref string) (string, error) {
+	if match := channelPattern.FindStringSubmatch(ref); match != nil {
+		return match[1], nil
+	}
+
+	id, err := b.slack.FindChannelByName(strings.TrimPrefix(ref, "#"))
+	if err != nil {
+		b.logger.Errorf("unable to resolve channel=%s; err=%s", ref, err.Error())
+		return "", fmt.Errorf(ErrMsgUnknownChannel, ref)
+	}
+	return id, nil
+}
+
+// mirrorMessage reposts a source channel message, translated, in each of
+// the channel's mirrors, keeping thread replies in the mirrored thread
+func (b *Bot) mirrorMessage(ev *slackevents.MessageEvent, links []MirrorLink) error {
+	sourceLanguage, ok := b.detector.Detect(ev.Text)
+	if !ok {
+		b.logger.Infof("unable to determine language of msg to mirror in channel=%s", ev.Channel)
+		return nil
+	}
+
+	options, err := b.attribution(ev.User)
+	if err != nil {
+		return err
+	}
+
+	for _, link := range links {
+		body := ev.Text
+		if clients.LanguageCode(link.Language) != clients.LanguageCode(sourceLanguage) {
+			if body, err = b.translate(b.ctx, sourceLanguage, "", link.Language, "", ev.Text); err != nil {
+				b.logger.Errorf("unable to translate msg=%s for mirror channel=%s; err=%s", ev.Text, link.Target, err.Error())
+				return fmt.Errorf(ErrMsgInternalServerError)
+			}
+		}
+
+		opts := append([]slack.MsgOption{slack.MsgOptionText(body, false)}, options...)
+		threadTimestamp := ev.TimeStamp
+		if ev.ThreadTimeStamp != "" {
+			threadTimestamp = ev.ThreadTimeStamp
+			if mirrorTimestamp, found := b.mirrored.Get(mirrorKey(ev.Channel, ev.ThreadTimeStamp) + ":" + link.Target); found {
+				opts = append(opts, slack.MsgOptionTS(mirrorTimestamp.(string)))
+			}
+		}
+
+		timestamp, err := b.slack.PostMessage(link.Target, opts...)
+		if err != nil {
+			b.logger.Errorf("unable to post to mirror channel=%s; err=%s", link.Target, err.Error())
+			continue
+		}
+
+		if ev.ThreadTimeStamp == "" {
+			b.mirrored.Set(mirrorKey(ev.Channel, ev.TimeStamp)+":"+link.Target, timestamp, cache.DefaultExpiration)
+		}
+		b.mirrored.Set(mirrorKey(link.Target, timestamp), mirroredMessage{
+			SourceChannel:   ev.Channel,
+			SourceTimestamp: threadTimestamp,
+			SourceLanguage:  sourceLanguage,
+		}, cache.DefaultExpiration)
+		b.trackReply(ev.Channel, ev.TimeStamp, trackedReply{
+			Channel:        link.Target,
+			Timestamp:      timestamp,
+			SourceLanguage: sourceLanguage,
+			Targets:        []translationTarget{{Language: link.Language}},
+		})
+	}
+
+	return nil
+}
+
+// mirrorReply translates a thread reply in a mirror channel back into the
+// thread of the source message it mirrors
+func (b *Bot) mirrorReply(ev *slackevents.MessageEvent) error {
+	if ev.ThreadTimeStamp == "" {
+		return nil
+	}
+	found, ok := b.mirrored.Get(mirrorKey(ev.Channel, ev.ThreadTimeStamp))
+	if !ok {
+		return nil
+	}
+	origin := found.(mirroredMessage)
+
+	body := ev.Text
+	if sourceLanguage, ok := b.detector.Detect(ev.Text); ok && clients.LanguageCode(sourceLanguage) != clients.LanguageCode(origin.SourceLanguage) {
+		var err error
+		if body, err = b.translate(b.ctx, sourceLanguage, "", origin.SourceLanguage, "", ev.Text); err != nil {
+			b.logger.Errorf("unable to translate mirror reply=%s; err=%s", ev.Text, err.Error())
+			return fmt.Errorf(ErrMsgInternalServerError)
+		}
+	}
+
+	options, err := b.attribution(ev.User)
+	if err != nil {
+		return err
+	}
+
+	opts := append([]slack.MsgOption{
+		slack.MsgOptionText(body, false),
+		slack.MsgOptionTS(origin.SourceTimestamp),
+	}, options...)
+	if _, err := b.slack.PostMessage(origin.SourceChannel, opts...); err != nil {
+		b.logger.Errorf("unable to post mirror reply to channel=%s; err=%s", origin.SourceChannel, err.Error())
+		return fmt.Errorf(ErrMsgInternalServerError)
+	}
+	return nil
+}
+
+// attribution returns the options posting a message under a user's name and avatar
+func (b *Bot) attribution(userID string) ([]slack.MsgOption, error) {
+	var user *clients.SlackUser
+	if cached, found := b.cache.Get("user:" + userID); found {
+		user = cached.(*clients.SlackUser)
+	} else {
+		var err error
+		if user, err = b.slack.GetUser(userID); err != nil {
+			b.logger.Errorf("unable to get user=%s; err=%s", userID, err.Error())
+			return nil, fmt.Errorf(ErrMsgInternalServerError)
+		}
+		b.cache.Set("user:"+userID, user, cache.DefaultExpiration)
+	}
+
+	options := []slack.MsgOption{slack.MsgOptionUsername(user.Name)}
+	if user.ImageURL != "" {
+		options = append(options, slack.MsgOptionIconURL(user.ImageURL))
+	}
+	return options, nil
+}
+
+// postEphemeral replies privately to the user who issued a command
+func (b *Bot) postEphemeral(command slack.SlashCommand, text string) error {
+	return b.slack.PostEphemeralMessage(command.ChannelID, command.UserID, slack.MsgOptionText(text, false))
+}
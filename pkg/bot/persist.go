@@ -0,0 +1,116 @@
+/*
+ * File: persist.go
+ * Project: bot
+ * File Created: Saturday, 17th October 2026 6:27:35 am
+ * Author: Mark Mester (mmester6016@gmail.com)
+ * -----
+ * Last Modified: Saturday, 17th October 2026 7:19:08 am
+ * Modified By: Mark Mester (mmester6016@gmail.com>)
+ */
+package slackbot
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/markmester/fanyi-slackbot/pkg/clients"
+)
+
+// persist writes the configuration through to the datastore as soon as it
+// changes, so a crash doesn't lose it. Changes to the auto-translation of
+// channels are persisted by the detector's OnChange hook. A failed write is
+// retried by the snapshot loop.
+func (b *Bot) persist() {
+	b.markDirty()
+
+	if err := b.save(); err != nil {
+		if isConflict(err) {
+			b.logger.Errorf("not persisting configuration, as it was changed by another instance of the bot; restart to reload it: %s", err.Error())
+			return
+		}
+		b.logger.Errorf("error persisting configuration to datastore; will retry: %s", err.Error())
+	}
+}
+
+// markDirty records an unsaved change and (re)starts the snapshot debounce
+func (b *Bot) markDirty() {
+	atomic.StoreInt32(b.dirty, 1)
+
+	select {
+	case b.changed <- struct{}{}:
+	default:
+	}
+}
+
+// snapshotLoop snapshots the configuration once it has been quiet for
+// snapshotDebounce after a change that wasn't saved, e.g. as the write-through
+// failed, and every snapshotInterval regardless. It stops writing once
+// another writer changed the stored configuration, and returns on Shutdown.
+func (b *Bot) snapshotLoop() {
+	ticker := time.NewTicker(snapshotInterval)
+	defer ticker.Stop()
+
+	debounce := time.NewTimer(snapshotDebounce)
+	debounce.Stop()
+
+	for {
+		select {
+		case <-b.ctx.Done():
+			return
+		case <-b.changed:
+			debounce.Reset(snapshotDebounce)
+			continue
+		case <-debounce.C:
+			if atomic.LoadInt32(b.dirty) == 0 {
+				continue
+			}
+		case <-ticker.C:
+		}
+
+		if atomic.LoadInt32(b.conflicted) == 1 {
+			continue
+		}
+		if err := b.save(); err != nil {
+			b.logger.Errorf("error saving configuration snapshot: %s", err.Error())
+		}
+	}
+}
+
+// save writes the configuration document to the datastore. Once another
+// writer changed the stored document, it is never overwritten: there is no
+// telling which changes should win.
+func (b *Bot) save() error {
+	b.saveMu.Lock()
+	defer b.saveMu.Unlock()
+
+	if atomic.LoadInt32(b.conflicted) == 1 {
+		return errors.Wrapf(clients.ErrDatastoreConflict, "configuration was changed by another writer")
+	}
+
+	// Changes made while saving mark the configuration dirty again
+	atomic.StoreInt32(b.dirty, 0)
+
+	jsonBytes, err := json.Marshal(b.document())
+	if err != nil {
+		return errors.Wrapf(err, "error serializing configuration")
+	}
+	if err := b.datastore.Set(datastoreKey, jsonBytes); err != nil {
+		if isConflict(err) {
+			atomic.StoreInt32(b.conflicted, 1)
+		} else {
+			atomic.StoreInt32(b.dirty, 1)
+		}
+		return errors.Wrapf(err, "error persisting configuration")
+	}
+
+	return nil
+}
+
+// isConflict reports whether a write failed because the stored document was
+// changed by another writer
+func isConflict(err error) bool {
+	return errors.Cause(err) == clients.ErrDatastoreConflict
+}
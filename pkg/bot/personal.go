@@ -0,0 +1,241 @@
+/*
+ * File: personal.go
+ * Project: bot
+ * File Created: Saturday, 17th October 2026 6:47:25 am
+ * Author: Mark Mester (mmester6016@gmail.com)
+ * -----
+ * Last Modified: Saturday, 17th October 2026 6:56:30 am
+ * Modified By: Mark Mester (mmester6016@gmail.com>)
+ */
+package slackbot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+
+	"github.com/markmester/fanyi-slackbot/pkg/clients"
+)
+
+// Preferences holds the preferred language of each user and the channels
+// whose members are sent personal translations instead of public replies
+type Preferences struct {
+	mu sync.RWMutex
+
+	Languages map[string]string `json:"languages"`
+	Personal  map[string]bool   `json:"personal"`
+}
+
+func NewPreferences() *Preferences {
+	return &Preferences{
+		Languages: map[string]string{},
+		Personal:  map[string]bool{},
+	}
+}
+
+// Language returns the user's preferred language, if any
+func (p *Preferences) Language(user string) string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.Languages[user]
+}
+
+// SetLanguage sets the user's preferred language; an empty language clears it
+func (p *Preferences) SetLanguage(user, language string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if language == "" {
+		delete(p.Languages, user)
+		return
+	}
+	p.Languages[user] = language
+}
+
+// Users returns the users with a preferred language
+func (p *Preferences) Users() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	users := []string{}
+	for user := range p.Languages {
+		users = append(users, user)
+	}
+	sort.Strings(users)
+	return users
+}
+
+// IsPersonal reports whether the channel's members are sent personal translations
+func (p *Preferences) IsPersonal(channel string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.Personal[channel]
+}
+
+// SetPersonal turns personal translations of the channel on or off,
+// reporting whether that changed anything
+func (p *Preferences) SetPersonal(channel string, on bool) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.Personal[channel] == on {
+		return false
+	}
+	if on {
+		p.Personal[channel] = true
+	} else {
+		delete(p.Personal, channel)
+	}
+	return true
+}
+
+// PersonalChannels returns the channels sent personal translations
+func (p *Preferences) PersonalChannels() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	channels := []string{}
+	for channel := range p.Personal {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+// handleMeCommand handles `/translate me [<language>|off]`, which shows,
+// sets or clears the user's preferred language
+func (b *Bot) handleMeCommand(command slack.SlashCommand, args []string) error {
+	user := b.scope(command.UserID)
+	language := strings.Join(args, " ")
+
+	switch {
+	case language == "":
+		if current := b.preferences.Language(user); current != "" {
+			return b.postEphemeral(command, fmt.Sprintf("Your preferred language is %s.", current))
+		}
+		return b.postEphemeral(command, "You haven't set a preferred language. Usage: `/translate me <language>`")
+
+	case language == "off":
+		b.preferences.SetLanguage(user, "")
+		b.persist()
+		return b.postEphemeral(command, "Your preferred language has been cleared.")
+	}
+
+	if clients.LanguageCode(language) == "" {
+		return b.postEphemeral(command, fmt.Sprintf("Sorry, '%s' is not a supported language.", language))
+	}
+
+	b.preferences.SetLanguage(user, language)
+	b.logger.Infof("set preferred language of user=%s to %s", command.UserID, language)
+	b.persist()
+	return b.postEphemeral(command, fmt.Sprintf("Your preferred language is now %s. In channels with personal translations, "+
+		"messages in other languages will be translated for you privately.", language))
+}
+
+// handlePersonalCommand handles `/translate personal on|off`
+func (b *Bot) handlePersonalCommand(command slack.SlashCommand, args []string) error {
+	if len(args) != 1 || (args[0] != "on" && args[0] != "off") {
+		return b.postEphemeral(command, "Usage: `/translate personal on` or `/translate personal off`")
+	}
+
+	on := args[0] == "on"
+	if !b.preferences.SetPersonal(b.scope(command.ChannelID), on) {
+		return b.postEphemeral(command, fmt.Sprintf("Personal translations are already %s in this channel.", args[0]))
+	}
+	b.logger.Infof("turned personal translations of channel=%s %s", command.ChannelID, args[0])
+	b.persist()
+
+	text := "Personal translations stopped."
+	if on {
+		text = "Personal translations activated: members who set a language with `/translate me <language>` " +
+			"will privately receive translations of messages in other languages."
+	}
+	if _, err := b.slack.PostMessage(command.ChannelID, slack.MsgOptionText(text, false)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// postPersonalTranslations privately sends each channel member with a
+// preferred language a translation of the message, unless it is already
+// in their language. Each language is translated once.
+func (b *Bot) postPersonalTranslations(ev *slackevents.MessageEvent) error {
+	members, err := b.members(ev.Channel)
+	if err != nil {
+		return err
+	}
+
+	readers := map[string][]string{}
+	for _, member := range members {
+		if member == ev.User {
+			continue
+		}
+		if language := b.preferences.Language(b.scope(member)); language != "" {
+			readers[language] = append(readers[language], member)
+		}
+	}
+	if len(readers) == 0 {
+		return nil
+	}
+
+	sourceLanguage, ok := b.detector.Detect(ev.Text)
+	if !ok {
+		b.logger.Infof("unable to determine language of msg in personal channel=%s", ev.Channel)
+		return nil
+	}
+
+	history := b.messageContext(ev)
+	for language, users := range readers {
+		if clients.LanguageCode(language) == clients.LanguageCode(sourceLanguage) {
+			continue
+		}
+
+		body, err := b.translate(b.ctx, sourceLanguage, "", language, "", ev.Text, clients.WithHistory(history))
+		if err != nil {
+			b.logger.Errorf("unable to provide personal translation for msg=%s from %s->%s; err=%s", ev.Text, sourceLanguage, language, err.Error())
+			return fmt.Errorf(ErrMsgInternalServerError)
+		}
+
+		options := []slack.MsgOption{
+			slack.MsgOptionText(body, false),
+			slack.MsgOptionBlocks(
+				slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, body, false, false), nil, nil),
+				slack.NewContextBlock("", slack.NewTextBlockObject(slack.MarkdownType,
+					fmt.Sprintf("Translated from %s for you · <@%s>'s message", sourceLanguage, ev.User), false, false)),
+			),
+		}
+		if ev.ThreadTimeStamp != "" {
+			options = append(options, slack.MsgOptionTS(ev.ThreadTimeStamp))
+		}
+
+		for _, user := range users {
+			if err := b.slack.PostEphemeralMessage(ev.Channel, user, options...); err != nil {
+				b.logger.Errorf("unable to post personal translation to user=%s; err=%s", user, err.Error())
+			}
+		}
+	}
+
+	return nil
+}
+
+// members returns the members of a channel
+func (b *Bot) members(channel string) ([]string, error) {
+	if cached, found := b.cache.Get("members:" + channel); found {
+		return cached.([]string), nil
+	}
+
+	members, err := b.slack.GetMembers(channel)
+	if err != nil {
+		b.logger.Errorf("unable to get members of channel=%s; err=%s", channel, err.Error())
+		return nil, fmt.Errorf(ErrMsgInternalServerError)
+	}
+	b.cache.Set("members:"+channel, members, cache.DefaultExpiration)
+	return members, nil
+}
@@ -0,0 +1,225 @@
+/*
+ * File: replies.go
+ * Project: bot
+ * File Created: Saturday, 17th October 2026 6:19:13 am
+ * Author: Mark Mester (mmester6016@gmail.com)
+ * -----
+ * Last Modified: Saturday, 17th October 2026 7:02:38 am
+ * Modified By: Mark Mester (mmester6016@gmail.com>)
+ */
+package slackbot
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/pkg/errors"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+
+	"github.com/markmester/fanyi-slackbot/pkg/clients"
+)
+
+// translationTarget is a language a tracked reply holds a translation into
+type translationTarget struct {
+	Language string
+	Dialect  string
+	// Body is the translation last posted
+	Body string
+}
+
+// trackedReply is a translation the bot posted for a source message, kept
+// so the translation can be updated or removed along with its source
+type trackedReply struct {
+	// Channel the translation was posted in; the source channel if empty
+	Channel   string
+	Timestamp string
+
+	SourceLanguage string
+	SourceDialect  string
+	// Targets hold a section of the reply each
+	Targets []translationTarget
+}
+
+// channel returns the channel the reply was posted in
+func (r trackedReply) channel(source string) string {
+	if r.Channel != "" {
+		return r.Channel
+	}
+	return source
+}
+
+// feedbackRef identifies the reply's translation into target of the source message at timestamp
+func (r trackedReply) feedbackRef(timestamp string, target translationTarget) feedbackRef {
+	return feedbackRef{
+		Timestamp:      timestamp,
+		SourceLanguage: r.SourceLanguage,
+		SourceDialect:  r.SourceDialect,
+		TargetLanguage: target.Language,
+		TargetDialect:  target.Dialect,
+	}
+}
+
+// message returns the options posting the reply to the source message at timestamp.
+// In-thread translations carry feedback actions; mirrored copies don't.
+func (r trackedReply) message(timestamp string) []slack.MsgOption {
+	if r.Channel != "" {
+		return []slack.MsgOption{slack.MsgOptionText(r.Targets[0].Body, false)}
+	}
+
+	return translationMessage(r.sections(timestamp)...)
+}
+
+// sections returns the reply's translations of the source message at timestamp
+func (r trackedReply) sections(timestamp string) []translationSection {
+	sections := []translationSection{}
+	for _, target := range r.Targets {
+		sections = append(sections, translationSection{Ref: r.feedbackRef(timestamp, target), Body: target.Body})
+	}
+	return sections
+}
+
+// withBody returns a copy of the reply with the translation into language replaced
+func (r trackedReply) withBody(language, body string) trackedReply {
+	targets := make([]translationTarget, len(r.Targets))
+	copy(targets, r.Targets)
+	for i := range targets {
+		if targets[i].Language == language {
+			targets[i].Body = body
+		}
+	}
+	r.Targets = targets
+	return r
+}
+
+func replyKey(channel, timestamp string) string {
+	return channel + ":" + timestamp
+}
+
+// trackedReplies returns the translations posted for a source message
+func (b *Bot) trackedReplies(channel, timestamp string) []trackedReply {
+	if replies, found := b.replies.Get(replyKey(channel, timestamp)); found {
+		return replies.([]trackedReply)
+	}
+	return nil
+}
+
+func (b *Bot) trackReply(channel, timestamp string, reply trackedReply) {
+	replies := append(b.trackedReplies(channel, timestamp), reply)
+	b.replies.Set(replyKey(channel, timestamp), replies, cache.DefaultExpiration)
+}
+
+// findReply returns the in-thread translation posted at reply for the source message at timestamp
+func (b *Bot) findReply(channel, timestamp, reply string) (trackedReply, bool) {
+	for _, tracked := range b.trackedReplies(channel, timestamp) {
+		if tracked.Channel == "" && tracked.Timestamp == reply {
+			return tracked, true
+		}
+	}
+	return trackedReply{}, false
+}
+
+// updateReply replaces the tracked translation posted at reply.Timestamp
+func (b *Bot) updateReply(channel, timestamp string, reply trackedReply) {
+	replies := append([]trackedReply{}, b.trackedReplies(channel, timestamp)...)
+	for i := range replies {
+		if replies[i].channel(channel) == reply.channel(channel) && replies[i].Timestamp == reply.Timestamp {
+			replies[i] = reply
+		}
+	}
+	b.replies.Set(replyKey(channel, timestamp), replies, cache.DefaultExpiration)
+}
+
+// translateTargets translates text into each of the reply's targets concurrently
+func (b *Bot) translateTargets(reply trackedReply, text string, opts ...clients.TranslateOption) (trackedReply, error) {
+	targets := make([]translationTarget, len(reply.Targets))
+	errs := make([]error, len(reply.Targets))
+
+	var wg sync.WaitGroup
+	for i, target := range reply.Targets {
+		wg.Add(1)
+		go func(i int, target translationTarget) {
+			defer wg.Done()
+			target.Body, errs[i] = b.translate(b.ctx, reply.SourceLanguage, reply.SourceDialect, target.Language, target.Dialect, text, opts...)
+			targets[i] = target
+		}(i, target)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return reply, errors.Wrapf(err, "%s->%s", reply.SourceLanguage, reply.Targets[i].Language)
+		}
+	}
+	reply.Targets = targets
+	return reply, nil
+}
+
+// postTranslation translates the text of the source message at timestamp
+// into each of the reply's targets, replies with them in the given thread
+// and remembers the reply so it can follow edits and deletion of the source
+func (b *Bot) postTranslation(channel, timestamp, threadTimestamp, text string, reply trackedReply, opts ...clients.TranslateOption) error {
+	reply, err := b.translateTargets(reply, text, opts...)
+	if err != nil {
+		b.logger.Errorf("unable to provide translation for msg=%s; err=%s", text, err.Error())
+		return fmt.Errorf(ErrMsgInternalServerError)
+	}
+
+	reply.Timestamp, err = b.slack.PostMessage(channel,
+		append(reply.message(timestamp), slack.MsgOptionTS(threadTimestamp))...,
+	)
+	if err != nil {
+		b.logger.Errorf("unable to post translation for msg=%s from %s; err=%s", text, reply.SourceLanguage, err.Error())
+		return fmt.Errorf(ErrMsgInternalServerError)
+	}
+
+	b.trackReply(channel, timestamp, reply)
+	return nil
+}
+
+// handleMessageChangedEvent re-translates an edited message, updating the
+// translations previously posted for it
+func (b *Bot) handleMessageChangedEvent(ev *slackevents.MessageEvent) error {
+	msg := ev.Message
+	if msg == nil || msg.BotID != "" || msg.Text == "" {
+		return nil
+	}
+	// Unfurls and thread replies also change the message; only the text matters here
+	if ev.PreviousMessage != nil && ev.PreviousMessage.Text == msg.Text {
+		return nil
+	}
+
+	for _, reply := range b.trackedReplies(ev.Channel, msg.TimeStamp) {
+		reply, err := b.translateTargets(reply, msg.Text)
+		if err != nil {
+			b.logger.Errorf("unable to re-translate edited msg=%s; err=%s", msg.Text, err.Error())
+			return fmt.Errorf(ErrMsgInternalServerError)
+		}
+
+		if err := b.slack.UpdateMessage(reply.channel(ev.Channel), reply.Timestamp, reply.message(msg.TimeStamp)...); err != nil {
+			b.logger.Errorf("unable to update translation for edited msg=%s; err=%s", msg.Text, err.Error())
+			return fmt.Errorf(ErrMsgInternalServerError)
+		}
+		b.updateReply(ev.Channel, msg.TimeStamp, reply)
+	}
+
+	return nil
+}
+
+// handleMessageDeletedEvent removes the translations posted for a deleted message
+func (b *Bot) handleMessageDeletedEvent(ev *slackevents.MessageEvent) error {
+	if ev.PreviousMessage == nil {
+		return nil
+	}
+
+	timestamp := ev.PreviousMessage.TimeStamp
+	for _, reply := range b.trackedReplies(ev.Channel, timestamp) {
+		if err := b.slack.DeleteMessage(reply.channel(ev.Channel), reply.Timestamp); err != nil {
+			b.logger.Errorf("unable to delete translation of deleted msg ts=%s; err=%s", timestamp, err.Error())
+		}
+	}
+	b.replies.Delete(replyKey(ev.Channel, timestamp))
+
+	return nil
+}
@@ -0,0 +1,109 @@
+/*
+ * File: shortcut.go
+ * Project: bot
+ * File Created: Saturday, 17th October 2026 6:49:38 am
+ * Author: Mark Mester (mmester6016@gmail.com)
+ * -----
+ * Last Modified: Saturday, 17th October 2026 6:49:38 am
+ * Modified By: Mark Mester (mmester6016@gmail.com>)
+ */
+package slackbot
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/slack-go/slack"
+
+	"github.com/markmester/fanyi-slackbot/pkg/clients"
+)
+
+const (
+	// callbackTranslateMessage is the callback ID of the "Translate to my
+	// language…" message shortcut; it must match manifest.yml
+	callbackTranslateMessage = "translate-message"
+
+	// Callback and block IDs of the translate modal
+	callbackTranslateModal = "translate-message-modal"
+	blockTargetLanguage    = "translate-target-language"
+	actionTargetLanguage   = "translate-target-language-select"
+)
+
+// translateMetadata is carried through the translate modal as its private metadata
+type translateMetadata struct {
+	Channel   string `json:"c"`
+	Timestamp string `json:"ts"`
+	Thread    string `json:"th,omitempty"`
+}
+
+// handleTranslateShortcut opens a modal asking which language to privately
+// translate the message the shortcut was used on into
+func (b *Bot) handleTranslateShortcut(interaction slack.InteractionCallback) error {
+	metadata, _ := json.Marshal(translateMetadata{
+		Channel:   interaction.Channel.ID,
+		Timestamp: interaction.Message.Timestamp,
+		Thread:    interaction.Message.ThreadTimestamp,
+	})
+
+	options := []*slack.OptionBlockObject{}
+	for _, language := range clients.SupportedLanguages() {
+		options = append(options, slack.NewOptionBlockObject(language, slack.NewTextBlockObject(slack.PlainTextType, language, false, false), nil))
+	}
+	selectLanguage := slack.NewOptionsSelectBlockElement(slack.OptTypeStatic,
+		slack.NewTextBlockObject(slack.PlainTextType, "Select a language", false, false),
+		actionTargetLanguage, options...)
+
+	// Start from the user's preferred language
+	if preferred := clients.LanguageCode(b.preferences.Language(b.scope(interaction.User.ID))); preferred != "" {
+		for _, option := range options {
+			if clients.LanguageCode(option.Value) == preferred {
+				selectLanguage.InitialOption = option
+			}
+		}
+	}
+
+	return b.slack.OpenView(interaction.TriggerID, slack.ModalViewRequest{
+		Type:            slack.VTModal,
+		CallbackID:      callbackTranslateModal,
+		PrivateMetadata: string(metadata),
+		Title:           slack.NewTextBlockObject(slack.PlainTextType, "Translate message", false, false),
+		Submit:          slack.NewTextBlockObject(slack.PlainTextType, "Translate", false, false),
+		Close:           slack.NewTextBlockObject(slack.PlainTextType, "Cancel", false, false),
+		Blocks: slack.Blocks{BlockSet: []slack.Block{
+			slack.NewInputBlock(blockTargetLanguage,
+				slack.NewTextBlockObject(slack.PlainTextType, "Translate to", false, false),
+				slack.NewTextBlockObject(slack.PlainTextType, "The translation is only shown to you", false, false),
+				selectLanguage,
+			),
+		}},
+	})
+}
+
+// handleTranslateSubmission privately posts the translation of the message
+// into the language picked in the translate modal
+func (b *Bot) handleTranslateSubmission(interaction slack.InteractionCallback) error {
+	var metadata translateMetadata
+	if err := json.Unmarshal([]byte(interaction.View.PrivateMetadata), &metadata); err != nil {
+		return fmt.Errorf("invalid translate metadata: %s", err.Error())
+	}
+
+	language := ""
+	if interaction.View.State != nil {
+		language = interaction.View.State.Values[blockTargetLanguage][actionTargetLanguage].SelectedOption.Value
+	}
+
+	msg, err := b.slack.GetMessage(metadata.Channel, metadata.Timestamp)
+	if err != nil {
+		b.logger.Errorf("unable to get msg to translate; err=%s", err.Error())
+		b.postErrorMessage(metadata.Channel, interaction.User.ID, metadata.Thread)
+		return err
+	}
+
+	body, err := b.translateOnDemand(language, msg.Text)
+	if err != nil {
+		body = err.Error()
+	}
+	return b.slack.PostEphemeralMessage(metadata.Channel, interaction.User.ID,
+		slack.MsgOptionText(body, false),
+		slack.MsgOptionTS(metadata.Thread))
+}
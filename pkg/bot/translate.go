@@ -0,0 +1,76 @@
+/*
+ * File: translate.go
+ * Project: bot
+ * File Created: Saturday, 17th October 2026 6:17:23 am
+ * Author: Mark Mester (mmester6016@gmail.com)
+ * -----
+ * Last Modified: Saturday, 17th October 2026 6:23:08 am
+ * Modified By: Mark Mester (mmester6016@gmail.com>)
+ */
+package slackbot
+
+import (
+	"context"
+	"strings"
+
+	"github.com/markmester/fanyi-slackbot/pkg/clients"
+)
+
+// translate translates msg while preserving its slack markup. Entities are
+// swapped for placeholders before translation and restored afterwards; if
+// the translator mangles a placeholder, the plain text between entities is
+// translated piecewise instead so the markup can't be lost.
+func (b *Bot) translate(ctx context.Context, fromLanguage, fromDialect, toLanguage, toDialect, msg string, opts ...clients.TranslateOption) (string, error) {
+	m := tokenizeMarkup(msg)
+	if len(m.entities) == 0 {
+		return b.translateText(ctx, fromLanguage, fromDialect, toLanguage, toDialect, msg, opts...)
+	}
+
+	// A previous translation must use the same placeholders as the message
+	options := clients.NewTranslateOptions(opts...)
+	options.Previous = m.conceal(options.Previous)
+
+	translated, err := b.translateText(ctx, fromLanguage, fromDialect, toLanguage, toDialect, m.text, clients.WithOptions(options))
+	if err != nil {
+		return "", err
+	}
+	if restored, ok := m.restore(translated); ok {
+		return restored, nil
+	}
+
+	b.logger.Warnf("translation dropped markup placeholders; translating segments individually")
+
+	var sb strings.Builder
+	for _, segment := range m.segments {
+		if segment.isEntity || !hasWords(segment.text) {
+			sb.WriteString(segment.text)
+			continue
+		}
+
+		translated, err := b.translateText(ctx, fromLanguage, fromDialect, toLanguage, toDialect, segment.text, opts...)
+		if err != nil {
+			return "", err
+		}
+
+		// Keep the whitespace surrounding the segment, which translators tend to trim
+		leading := segment.text[:len(segment.text)-len(strings.TrimLeft(segment.text, " \t\n"))]
+		trailing := segment.text[len(strings.TrimRight(segment.text, " \t\n")):]
+		sb.WriteString(leading + strings.TrimSpace(translated) + trailing)
+	}
+	return sb.String(), nil
+}
+
+// translateText runs text through the configured translator, logging which
+// provider produced the translation when the translator reports it
+func (b *Bot) translateText(ctx context.Context, fromLanguage, fromDialect, toLanguage, toDialect, text string, opts ...clients.TranslateOption) (string, error) {
+	if translator, ok := b.translator.(clients.ProviderTranslator); ok {
+		translation, provider, err := translator.TranslateWithProvider(ctx, fromLanguage, fromDialect, toLanguage, toDialect, text, opts...)
+		if err != nil {
+			return "", err
+		}
+		b.logger.Infof("translation %s->%s provided by %s", fromLanguage, toLanguage, provider)
+		return translation, nil
+	}
+
+	return b.translator.Translate(ctx, fromLanguage, fromDialect, toLanguage, toDialect, text, opts...)
+}
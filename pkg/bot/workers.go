@@ -0,0 +1,195 @@
+/*
+ * File: workers.go
+ * Project: bot
+ * File Created: Saturday, 17th October 2026 6:33:09 am
+ * Author: Mark Mester (mmester6016@gmail.com)
+ * -----
+ * Last Modified: Saturday, 17th October 2026 7:27:37 am
+ * Modified By: Mark Mester (mmester6016@gmail.com>)
+ */
+package slackbot
+
+import (
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultWorkers is the number of events handled concurrently
+	DefaultWorkers = 8
+	// DefaultQueueSize is the number of events each worker queues
+	DefaultQueueSize = 100
+	// DefaultShutdownTimeout is how long pending events may take to finish on shutdown
+	DefaultShutdownTimeout = 20 * time.Second
+)
+
+// workerPool runs jobs on a fixed number of workers. Jobs are sharded by key
+// (the channel) so that jobs of the same key run one at a time in the order
+// they were submitted, and replies in a channel keep the order of its messages.
+type workerPool struct {
+	mu      sync.RWMutex
+	stopped bool
+
+	queues  []chan func()
+	pending int64
+	wg      sync.WaitGroup
+
+	// abandoned is set when stop times out; queued jobs are then skipped
+	abandoned int32
+
+	logger *zap.SugaredLogger
+}
+
+func newWorkerPool(workers, queueSize int, logger *zap.SugaredLogger) *workerPool {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize < 1 {
+		queueSize = 1
+	}
+
+	p := &workerPool{logger: logger}
+	for i := 0; i < workers; i++ {
+		queue := make(chan func(), queueSize)
+		p.queues = append(p.queues, queue)
+
+		p.wg.Add(1)
+		go p.work(queue)
+	}
+	return p
+}
+
+// submit queues the job behind the earlier jobs of its key. It never blocks
+// the caller, which receives the events of every channel: if the queue is
+// full, the job is dropped. It returns false if the job was not queued.
+func (p *workerPool) submit(key string, job func()) bool {
+	return p.enqueue(key, job, 0)
+}
+
+// submitWait is submit for jobs that shouldn't be dropped lightly, e.g. the
+// edit of a message whose translation is still queued: if the queue is full,
+// it waits up to timeout for room before dropping the job.
+func (p *workerPool) submitWait(key string, job func(), timeout time.Duration) bool {
+	return p.enqueue(key, job, timeout)
+}
+
+func (p *workerPool) enqueue(key string, job func(), timeout time.Duration) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.stopped {
+		return false
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	queue := p.queues[hash.Sum32()%uint32(len(p.queues))]
+
+	atomic.AddInt64(&p.pending, 1)
+	select {
+	case queue <- job:
+		return true
+	default:
+	}
+
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		select {
+		case queue <- job:
+			return true
+		case <-timer.C:
+		}
+	}
+
+	atomic.AddInt64(&p.pending, -1)
+	p.logger.Errorf("event queue for key=%s is full; dropping event (queue depth=%d)", key, p.depth())
+	return false
+}
+
+// start runs the job right away on a goroutine of its own, for work that
+// can't wait behind queued jobs, e.g. opening a modal before its trigger
+// expires. It returns false if the pool has been stopped.
+func (p *workerPool) start(job func()) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.stopped {
+		return false
+	}
+
+	atomic.AddInt64(&p.pending, 1)
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.run(job)
+	}()
+	return true
+}
+
+// depth returns the number of queued jobs that haven't finished yet
+func (p *workerPool) depth() int {
+	return int(atomic.LoadInt64(&p.pending))
+}
+
+// stop rejects later submissions and waits up to timeout for the queued jobs
+// to finish. If they don't finish in time, the jobs still queued are skipped
+// and false is returned; the caller should then abandon the running jobs and
+// wait for them.
+func (p *workerPool) stop(timeout time.Duration) bool {
+	p.mu.Lock()
+	if !p.stopped {
+		p.stopped = true
+		for _, queue := range p.queues {
+			close(queue)
+		}
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		atomic.StoreInt32(&p.abandoned, 1)
+		return false
+	}
+}
+
+// wait waits for the workers of a stopped pool to exit
+func (p *workerPool) wait() {
+	p.wg.Wait()
+}
+
+func (p *workerPool) work(queue chan func()) {
+	defer p.wg.Done()
+
+	for job := range queue {
+		p.run(job)
+	}
+}
+
+// run runs a job, recovering from panics so one bad event doesn't take down the bot
+func (p *workerPool) run(job func()) {
+	defer atomic.AddInt64(&p.pending, -1)
+	defer func() {
+		if r := recover(); r != nil {
+			p.logger.Errorf("recovered from panic handling event: %v", r)
+		}
+	}()
+
+	if atomic.LoadInt32(&p.abandoned) == 1 {
+		return
+	}
+	job()
+}
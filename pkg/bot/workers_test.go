@@ -0,0 +1,162 @@
+/*
+ * File: workers_test.go
+ * Project: bot
+ * File Created: Saturday, 17th October 2026 7:27:37 am
+ * Author: Mark Mester (mmester6016@gmail.com)
+ * -----
+ * Last Modified: Saturday, 17th October 2026 7:27:37 am
+ * Modified By: Mark Mester (mmester6016@gmail.com>)
+ */
+package slackbot
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestWorkerPoolOrder(t *testing.T) {
+	pool := newWorkerPool(4, 100, zap.NewNop().Sugar())
+
+	var mu sync.Mutex
+	handled := map[string][]int{}
+	for i := 0; i < 50; i++ {
+		for _, channel := range []string{"T1:C1", "T1:C2", "T2:C1"} {
+			i, channel := i, channel
+			if !pool.submit(channel, func() {
+				// Give later jobs the chance to overtake
+				time.Sleep(time.Duration(i%3) * time.Millisecond)
+
+				mu.Lock()
+				defer mu.Unlock()
+				handled[channel] = append(handled[channel], i)
+			}) {
+				t.Fatalf("job %d of %s was dropped", i, channel)
+			}
+		}
+	}
+	if !pool.stop(10 * time.Second) {
+		t.Fatalf("jobs did not finish")
+	}
+
+	want := []int{}
+	for i := 0; i < 50; i++ {
+		want = append(want, i)
+	}
+	for _, channel := range []string{"T1:C1", "T1:C2", "T2:C1"} {
+		if !reflect.DeepEqual(handled[channel], want) {
+			t.Errorf("jobs of %s ran in order %v", channel, handled[channel])
+		}
+	}
+}
+
+// blockedPool returns a pool with a single worker busy until release is
+// called, and a queue of queueSize
+func blockedPool(t *testing.T, queueSize int) (*workerPool, func()) {
+	t.Helper()
+
+	pool := newWorkerPool(1, queueSize, zap.NewNop().Sugar())
+	running, unblock := make(chan struct{}), make(chan struct{})
+	pool.submit("C1", func() {
+		close(running)
+		<-unblock
+	})
+	<-running
+
+	var once sync.Once
+	release := func() { once.Do(func() { close(unblock) }) }
+	t.Cleanup(func() {
+		release()
+		pool.stop(time.Second)
+	})
+	return pool, release
+}
+
+func TestWorkerPoolDropsWhenFull(t *testing.T) {
+	pool, release := blockedPool(t, 2)
+
+	ran := make(chan string, 10)
+	for _, job := range []string{"queued 1", "queued 2"} {
+		job := job
+		if !pool.submit("C1", func() { ran <- job }) {
+			t.Fatalf("%s was dropped with room in the queue", job)
+		}
+	}
+	// Jobs of other keys share the worker's queue
+	if pool.submit("C2", func() { ran <- "dropped" }) {
+		t.Fatalf("job was queued in a full queue")
+	}
+	if depth := pool.depth(); depth != 3 {
+		t.Errorf("depth = %d, want 3", depth)
+	}
+
+	release()
+	if !pool.stop(time.Second) {
+		t.Fatalf("jobs did not finish")
+	}
+	close(ran)
+	jobs := []string{}
+	for job := range ran {
+		jobs = append(jobs, job)
+	}
+	if want := []string{"queued 1", "queued 2"}; !reflect.DeepEqual(jobs, want) {
+		t.Errorf("ran %q, want %q", jobs, want)
+	}
+	if depth := pool.depth(); depth != 0 {
+		t.Errorf("depth = %d after stopping, want 0", depth)
+	}
+}
+
+func TestWorkerPoolSubmitWait(t *testing.T) {
+	pool, release := blockedPool(t, 1)
+	pool.submit("C1", func() {})
+
+	// Times out while the queue stays full
+	if pool.submitWait("C1", func() {}, 10*time.Millisecond) {
+		t.Fatalf("job was queued in a full queue")
+	}
+
+	// Queued once there is room
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		release()
+	}()
+	ran := make(chan struct{})
+	if !pool.submitWait("C1", func() { close(ran) }, 5*time.Second) {
+		t.Fatalf("job was dropped after room was made in the queue")
+	}
+	select {
+	case <-ran:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("job did not run")
+	}
+}
+
+func TestWorkerPoolStopped(t *testing.T) {
+	pool := newWorkerPool(2, 10, zap.NewNop().Sugar())
+	if !pool.stop(time.Second) {
+		t.Fatalf("empty pool did not stop")
+	}
+
+	if pool.submit("C1", func() {}) || pool.submitWait("C1", func() {}, time.Millisecond) || pool.start(func() {}) {
+		t.Errorf("stopped pool accepted a job")
+	}
+}
+
+func TestWorkerPoolRecovers(t *testing.T) {
+	pool := newWorkerPool(1, 10, zap.NewNop().Sugar())
+
+	ran := false
+	pool.submit("C1", func() { panic(fmt.Errorf("bad event")) })
+	pool.submit("C1", func() { ran = true })
+	if !pool.stop(time.Second) {
+		t.Fatalf("jobs did not finish")
+	}
+	if !ran {
+		t.Errorf("job after a panic did not run")
+	}
+}
@@ -0,0 +1,113 @@
+/*
+ * File: bolt.go
+ * Project: clients
+ * File Created: Saturday, 17th October 2026 6:28:43 am
+ * Author: Mark Mester (mmester6016@gmail.com)
+ * -----
+ * Last Modified: Saturday, 17th October 2026 6:28:43 am
+ * Modified By: Mark Mester (mmester6016@gmail.com>)
+ */
+package clients
+
+import (
+	"bytes"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltBucket holds all keys; namespaces are key prefixes
+var boltBucket = []byte("fanyi")
+
+// BoltClient stores data in an embedded bbolt database file
+type BoltClient struct {
+	db *bolt.DB
+}
+
+func NewBoltClient(path string) (*BoltClient, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to open database %s", path)
+	}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucket)
+		return err
+	}); err != nil {
+		db.Close()
+		return nil, errors.Wrapf(err, "unable to initialize database %s", path)
+	}
+
+	return &BoltClient{db: db}, nil
+}
+
+func (b *BoltClient) Get(key string) (data []byte, err error) {
+	err = b.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(boltBucket).Get([]byte(key))
+		if value == nil {
+			return os.ErrNotExist
+		}
+		// Values are only valid for the life of the transaction
+		data = append([]byte{}, value...)
+		return nil
+	})
+	return data, err
+}
+
+func (b *BoltClient) Set(key string, data []byte) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Put([]byte(key), data)
+	})
+}
+
+func (b *BoltClient) Delete(key string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Delete([]byte(key))
+	})
+}
+
+func (b *BoltClient) List(prefix string) ([]string, error) {
+	keys := []string{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(boltBucket).Cursor()
+		for k, _ := cursor.Seek([]byte(prefix)); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, _ = cursor.Next() {
+			keys = append(keys, string(k))
+		}
+		return nil
+	})
+	return keys, err
+}
+
+// Update applies the batch in a single transaction
+func (b *BoltClient) Update(fn func(batch Batch) error) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		batch := &boltBatch{bucket: tx.Bucket(boltBucket)}
+		if err := fn(batch); err != nil {
+			return err
+		}
+		return batch.err
+	})
+}
+
+func (b *BoltClient) Close() error {
+	return b.db.Close()
+}
+
+type boltBatch struct {
+	bucket *bolt.Bucket
+	err    error
+}
+
+func (b *boltBatch) Set(key string, data []byte) {
+	if b.err == nil {
+		b.err = b.bucket.Put([]byte(key), data)
+	}
+}
+
+func (b *boltBatch) Delete(key string) {
+	if b.err == nil {
+		b.err = b.bucket.Delete([]byte(key))
+	}
+}
@@ -0,0 +1,157 @@
+/*
+ * File: breaker_test.go
+ * Project: clients
+ * File Created: Saturday, 17th October 2026 7:24:25 am
+ * Author: Mark Mester (mmester6016@gmail.com)
+ * -----
+ * Last Modified: Saturday, 17th October 2026 7:24:25 am
+ * Modified By: Mark Mester (mmester6016@gmail.com>)
+ */
+package clients
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced clock for circuit breakers
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock(b *CircuitBreaker) *fakeClock {
+	c := &fakeClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	b.now = c.Now
+	return c
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+func TestCircuitBreakerThreshold(t *testing.T) {
+	b := NewCircuitBreaker(3, time.Minute)
+	newFakeClock(b)
+
+	b.Failure()
+	b.Failure()
+	if !b.Allow() || b.State() != "closed" {
+		t.Fatalf("breaker is %s below the threshold", b.State())
+	}
+
+	// Only consecutive failures count
+	b.Success()
+	b.Failure()
+	b.Failure()
+	if b.State() != "closed" {
+		t.Fatalf("breaker is %s after a success reset the failures", b.State())
+	}
+
+	b.Failure()
+	if b.State() != "open" {
+		t.Fatalf("breaker is %s at the threshold, want open", b.State())
+	}
+	if b.Allow() {
+		t.Errorf("open breaker allowed a call")
+	}
+}
+
+func TestCircuitBreakerCooldown(t *testing.T) {
+	b := NewCircuitBreaker(1, time.Minute)
+	clock := newFakeClock(b)
+
+	b.Failure()
+	clock.Advance(time.Minute - time.Second)
+	if b.Allow() {
+		t.Fatalf("breaker allowed a call before the cool-down elapsed")
+	}
+
+	clock.Advance(time.Second)
+	if !b.Allow() {
+		t.Fatalf("breaker did not allow a probe after the cool-down")
+	}
+	if b.State() != "half-open" {
+		t.Errorf("breaker is %s while probing, want half-open", b.State())
+	}
+	if b.Allow() {
+		t.Errorf("breaker allowed a second call while probing")
+	}
+
+	// A failed probe starts a new cool-down
+	b.Failure()
+	if b.State() != "open" || b.Allow() {
+		t.Fatalf("breaker is %s after a failed probe, want open", b.State())
+	}
+	clock.Advance(time.Minute)
+	if !b.Allow() {
+		t.Fatalf("breaker did not allow a probe after the second cool-down")
+	}
+
+	b.Success()
+	if b.State() != "closed" || !b.Allow() || !b.Allow() {
+		t.Errorf("breaker is %s after a successful probe, want closed", b.State())
+	}
+}
+
+func TestCircuitBreakerSingleProbe(t *testing.T) {
+	b := NewCircuitBreaker(1, time.Minute)
+	clock := newFakeClock(b)
+	b.Failure()
+	clock.Advance(time.Minute)
+
+	var allowed int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if b.Allow() {
+				atomic.AddInt32(&allowed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != 1 {
+		t.Errorf("%d concurrent probes were allowed, want 1", allowed)
+	}
+}
+
+func TestCircuitBreakerRelease(t *testing.T) {
+	b := NewCircuitBreaker(1, time.Minute)
+	clock := newFakeClock(b)
+
+	// Releasing a closed breaker changes nothing
+	b.Release()
+	if b.State() != "closed" {
+		t.Fatalf("breaker is %s after releasing a closed breaker", b.State())
+	}
+
+	b.Failure()
+	clock.Advance(time.Minute)
+	if !b.Allow() {
+		t.Fatalf("breaker did not allow a probe after the cool-down")
+	}
+
+	// An abandoned probe lets the next caller probe straight away
+	b.Release()
+	if b.State() != "open" {
+		t.Fatalf("breaker is %s after releasing a probe, want open", b.State())
+	}
+	if !b.Allow() {
+		t.Errorf("breaker did not allow a new probe after the probe was released")
+	}
+}
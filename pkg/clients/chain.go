@@ -0,0 +1,153 @@
+/*
+ * File: chain.go
+ * Project: clients
+ * File Created: Saturday, 17th October 2026 6:15:44 am
+ * Author: Mark Mester (mmester6016@gmail.com)
+ * -----
+ * Last Modified: Saturday, 17th October 2026 7:05:16 am
+ * Modified By: Mark Mester (mmester6016@gmail.com>)
+ */
+package clients
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultChainTimeout bounds how long a provider in a chain may take before falling back
+	DefaultChainTimeout = 30 * time.Second
+	// DefaultBreakerThreshold is the number of consecutive failures that trips a provider's breaker
+	DefaultBreakerThreshold = 5
+	// DefaultBreakerCooldown is how long a tripped provider is skipped before being probed again
+	DefaultBreakerCooldown = time.Minute
+)
+
+// ProviderTranslator is implemented by translators that can report which
+// provider produced a translation
+type ProviderTranslator interface {
+	Translator
+	TranslateWithProvider(ctx context.Context, fromLanguage, fromDialect, toLanguage, toDialect, msg string, opts ...TranslateOption) (translation, provider string, err error)
+}
+
+// ChainConfig configures the fallback behaviour of a TranslatorChain
+type ChainConfig struct {
+	Timeout          time.Duration
+	FailureThreshold int
+	Cooldown         time.Duration
+}
+
+// TranslatorChain tries an ordered list of translation providers, falling
+// back to the next provider when one fails or times out. Each provider is
+// guarded by a circuit breaker so a failing provider is skipped until its
+// cool-down has elapsed.
+type TranslatorChain struct {
+	config ChainConfig
+	links  []*chainLink
+}
+
+type chainLink struct {
+	name       string
+	translator Translator
+	breaker    *CircuitBreaker
+}
+
+func NewTranslatorChain(config ChainConfig) *TranslatorChain {
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultChainTimeout
+	}
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = DefaultBreakerThreshold
+	}
+	if config.Cooldown <= 0 {
+		config.Cooldown = DefaultBreakerCooldown
+	}
+
+	return &TranslatorChain{config: config}
+}
+
+// Add appends a provider to the end of the chain
+func (c *TranslatorChain) Add(name string, translator Translator) *TranslatorChain {
+	c.links = append(c.links, &chainLink{
+		name:       name,
+		translator: translator,
+		breaker:    NewCircuitBreaker(c.config.FailureThreshold, c.config.Cooldown),
+	})
+	return c
+}
+
+func (c *TranslatorChain) Translate(ctx context.Context, fromLanguage, fromDialect, toLanguage, toDialect, msg string, opts ...TranslateOption) (string, error) {
+	translation, _, err := c.TranslateWithProvider(ctx, fromLanguage, fromDialect, toLanguage, toDialect, msg, opts...)
+	return translation, err
+}
+
+// TranslateWithProvider returns the first successful translation in chain
+// order along with the name of the provider that produced it
+func (c *TranslatorChain) TranslateWithProvider(ctx context.Context, fromLanguage, fromDialect, toLanguage, toDialect, msg string, opts ...TranslateOption) (string, string, error) {
+	errs := []string{}
+	for _, link := range c.links {
+		if !link.breaker.Allow() {
+			errs = append(errs, fmt.Sprintf("%s: circuit open", link.name))
+			continue
+		}
+
+		translation, err := c.call(ctx, func(ctx context.Context) (string, error) {
+			return link.translator.Translate(ctx, fromLanguage, fromDialect, toLanguage, toDialect, msg, opts...)
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				// The caller gave up; this says nothing about the provider's health
+				link.breaker.Release()
+				return "", "", ctx.Err()
+			}
+			link.breaker.Failure()
+			log.Printf("translation provider %s failed (breaker %s); err=%s", link.name, link.breaker.State(), err.Error())
+			errs = append(errs, fmt.Sprintf("%s: %s", link.name, err.Error()))
+			continue
+		}
+
+		link.breaker.Success()
+		return translation, link.name, nil
+	}
+
+	return "", "", fmt.Errorf("all translation providers failed: %s", strings.Join(errs, "; "))
+}
+
+// Explain asks the first available provider able to explain translations
+func (c *TranslatorChain) Explain(ctx context.Context, fromLanguage, toLanguage, msg, translation string) (string, error) {
+	for _, link := range c.links {
+		explainer, ok := link.translator.(Explainer)
+		if !ok || !link.breaker.Allow() {
+			continue
+		}
+
+		explanation, err := c.call(ctx, func(ctx context.Context) (string, error) {
+			return explainer.Explain(ctx, fromLanguage, toLanguage, msg, translation)
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				link.breaker.Release()
+				return "", ctx.Err()
+			}
+			link.breaker.Failure()
+			log.Printf("translation provider %s failed to explain (breaker %s); err=%s", link.name, link.breaker.State(), err.Error())
+			continue
+		}
+
+		link.breaker.Success()
+		return explanation, nil
+	}
+
+	return "", fmt.Errorf("no translation provider is able to explain translations")
+}
+
+// call invokes a single provider, giving up once the chain timeout elapses
+func (c *TranslatorChain) call(ctx context.Context, fn func(ctx context.Context) (string, error)) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+	defer cancel()
+
+	return fn(ctx)
+}
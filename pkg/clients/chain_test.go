@@ -0,0 +1,188 @@
+/*
+ * File: chain_test.go
+ * Project: clients
+ * File Created: Saturday, 17th October 2026 7:24:25 am
+ * Author: Mark Mester (mmester6016@gmail.com)
+ * -----
+ * Last Modified: Saturday, 17th October 2026 7:24:25 am
+ * Modified By: Mark Mester (mmester6016@gmail.com>)
+ */
+package clients
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubTranslator returns a fixed translation or error, or blocks until its
+// context is done
+type stubTranslator struct {
+	mu          sync.Mutex
+	translation string
+	err         error
+	block       bool
+	calls       int
+	// started is closed on the first call when set
+	started chan struct{}
+}
+
+func (s *stubTranslator) Translate(ctx context.Context, fromLanguage, fromDialect, toLanguage, toDialect, msg string, opts ...TranslateOption) (string, error) {
+	s.mu.Lock()
+	s.calls++
+	if s.started != nil && s.calls == 1 {
+		close(s.started)
+	}
+	s.mu.Unlock()
+
+	if s.block {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+	return s.translation, s.err
+}
+
+func (s *stubTranslator) Calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.calls
+}
+
+type stubExplainer struct {
+	stubTranslator
+	explanation string
+}
+
+func (s *stubExplainer) Explain(ctx context.Context, fromLanguage, toLanguage, msg, translation string) (string, error) {
+	return s.explanation, s.err
+}
+
+func TestTranslatorChainFallthrough(t *testing.T) {
+	primary := &stubTranslator{err: errors.New("unexpected status 500")}
+	secondary := &stubTranslator{translation: "Hola"}
+	chain := NewTranslatorChain(ChainConfig{}).Add("primary", primary).Add("secondary", secondary)
+
+	translation, provider, err := chain.TranslateWithProvider(context.Background(), "English", "", "Spanish", "", "Hello")
+	if err != nil {
+		t.Fatalf("TranslateWithProvider: %s", err)
+	}
+	if translation != "Hola" || provider != "secondary" {
+		t.Errorf("TranslateWithProvider = %q from %s, want Hola from secondary", translation, provider)
+	}
+	if primary.Calls() != 1 || secondary.Calls() != 1 {
+		t.Errorf("calls = %d, %d; want 1, 1", primary.Calls(), secondary.Calls())
+	}
+}
+
+func TestTranslatorChainTimeout(t *testing.T) {
+	primary := &stubTranslator{block: true}
+	secondary := &stubTranslator{translation: "Hola"}
+	chain := NewTranslatorChain(ChainConfig{Timeout: 20 * time.Millisecond}).Add("primary", primary).Add("secondary", secondary)
+
+	start := time.Now()
+	translation, provider, err := chain.TranslateWithProvider(context.Background(), "English", "", "Spanish", "", "Hello")
+	if err != nil {
+		t.Fatalf("TranslateWithProvider: %s", err)
+	}
+	if translation != "Hola" || provider != "secondary" {
+		t.Errorf("TranslateWithProvider = %q from %s, want Hola from secondary", translation, provider)
+	}
+	// The timeout applies to each provider rather than the whole chain
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("falling back took %s", elapsed)
+	}
+}
+
+func TestTranslatorChainAllFail(t *testing.T) {
+	chain := NewTranslatorChain(ChainConfig{Timeout: 20 * time.Millisecond}).
+		Add("primary", &stubTranslator{err: errors.New("unexpected status 401")}).
+		Add("secondary", &stubTranslator{block: true})
+
+	_, _, err := chain.TranslateWithProvider(context.Background(), "English", "", "Spanish", "", "Hello")
+	if err == nil {
+		t.Fatalf("TranslateWithProvider succeeded with every provider failing")
+	}
+	for _, want := range []string{"primary: unexpected status 401", "secondary: context deadline exceeded"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err, want)
+		}
+	}
+}
+
+func TestTranslatorChainBreaker(t *testing.T) {
+	primary := &stubTranslator{err: errors.New("unexpected status 503")}
+	secondary := &stubTranslator{translation: "Hola"}
+	chain := NewTranslatorChain(ChainConfig{FailureThreshold: 2, Cooldown: time.Minute}).Add("primary", primary).Add("secondary", secondary)
+	clock := newFakeClock(chain.links[0].breaker)
+
+	translate := func() string {
+		t.Helper()
+		_, provider, err := chain.TranslateWithProvider(context.Background(), "English", "", "Spanish", "", "Hello")
+		if err != nil {
+			t.Fatalf("TranslateWithProvider: %s", err)
+		}
+		return provider
+	}
+
+	translate()
+	translate()
+	// The breaker is open, so the primary is skipped without being called
+	if provider := translate(); provider != "secondary" || primary.Calls() != 2 {
+		t.Fatalf("provider = %s after %d primary calls, want secondary after 2", provider, primary.Calls())
+	}
+
+	// Once the cool-down elapses the primary is probed, and used again once it recovers
+	clock.Advance(time.Minute)
+	primary.mu.Lock()
+	primary.translation, primary.err = "¡Hola!", nil
+	primary.mu.Unlock()
+	if provider := translate(); provider != "primary" || primary.Calls() != 3 {
+		t.Errorf("provider = %s after %d primary calls, want primary after 3", provider, primary.Calls())
+	}
+	if state := chain.links[0].breaker.State(); state != "closed" {
+		t.Errorf("breaker is %s after a successful probe, want closed", state)
+	}
+}
+
+func TestTranslatorChainCancelled(t *testing.T) {
+	primary := &stubTranslator{block: true, started: make(chan struct{})}
+	secondary := &stubTranslator{translation: "Hola"}
+	chain := NewTranslatorChain(ChainConfig{FailureThreshold: 1}).Add("primary", primary).Add("secondary", secondary)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-primary.started
+		cancel()
+	}()
+
+	if _, _, err := chain.TranslateWithProvider(ctx, "English", "", "Spanish", "", "Hello"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("TranslateWithProvider error = %v, want context.Canceled", err)
+	}
+	// Giving up says nothing about the providers' health
+	if secondary.Calls() != 0 {
+		t.Errorf("secondary was called after the caller gave up")
+	}
+	if state := chain.links[0].breaker.State(); state != "closed" {
+		t.Errorf("breaker is %s after the caller gave up, want closed", state)
+	}
+}
+
+func TestTranslatorChainExplain(t *testing.T) {
+	chain := NewTranslatorChain(ChainConfig{}).
+		Add("libretranslate", &stubTranslator{translation: "Hola"}).
+		Add("chat", &stubExplainer{explanation: "A greeting"})
+
+	explanation, err := chain.Explain(context.Background(), "English", "Spanish", "Hello", "Hola")
+	if err != nil || explanation != "A greeting" {
+		t.Errorf("Explain = %q, %v; want the explanation of the explaining provider", explanation, err)
+	}
+
+	chain = NewTranslatorChain(ChainConfig{}).Add("libretranslate", &stubTranslator{translation: "Hola"})
+	if _, err := chain.Explain(context.Background(), "English", "Spanish", "Hello", "Hola"); err == nil {
+		t.Errorf("Explain succeeded without an explaining provider")
+	}
+}
@@ -0,0 +1,131 @@
+/*
+ * File: chat.go
+ * Project: clients
+ * File Created: Saturday, 17th October 2026 6:15:00 am
+ * Author: Mark Mester (mmester6016@gmail.com)
+ * -----
+ * Last Modified: Saturday, 17th October 2026 6:23:08 am
+ * Modified By: Mark Mester (mmester6016@gmail.com>)
+ */
+package clients
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultChatBaseURL is the OpenAI API; any OpenAI compatible server may be used instead
+	DefaultChatBaseURL = "https://api.openai.com/v1"
+	// DefaultChatModel is used when no chat model has been configured
+	DefaultChatModel = "gpt-3.5-turbo"
+
+	chatSystemPrompt = "You are a translator. Translate the user's message as instructed and output only the translation, " +
+		"without quotes, notes or explanations. Preserve the tone, formatting, emoji and line breaks of the original message."
+)
+
+// ChatClient translates using the OpenAI chat completions protocol
+// (/v1/chat/completions) against any OpenAI compatible server
+type ChatClient struct {
+	http *http.Client
+
+	baseURL string
+	apiKey  string
+	model   string
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatCompletionRequest struct {
+	Model       string        `json:"model"`
+	Messages    []chatMessage `json:"messages"`
+	Temperature float32       `json:"temperature"`
+	MaxTokens   int           `json:"max_tokens,omitempty"`
+}
+
+type chatCompletionResponse struct {
+	ID      string `json:"id"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Index        int         `json:"index"`
+		Message      chatMessage `json:"message"`
+		FinishReason string      `json:"finish_reason"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		TotalTokens      int `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+func NewChatClient(apiKey, model, baseURL string) *ChatClient {
+	if model == "" {
+		model = DefaultChatModel
+	}
+	if baseURL == "" {
+		baseURL = DefaultChatBaseURL
+	}
+
+	return &ChatClient{
+		http:    &http.Client{},
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   model,
+	}
+}
+
+func (c *ChatClient) Translate(ctx context.Context, fromLanguage, fromDialect, toLanguage, toDialect, msg string, opts ...TranslateOption) (string, error) {
+	ask, err := translationPrompt(fromLanguage, fromDialect, toLanguage, toDialect, msg, opts...)
+	if err != nil {
+		return "", err
+	}
+
+	return c.complete(ctx, []chatMessage{
+		{Role: "system", Content: chatSystemPrompt},
+		{Role: "user", Content: ask},
+	})
+}
+
+func (c *ChatClient) Explain(ctx context.Context, fromLanguage, toLanguage, msg, translation string) (string, error) {
+	return c.complete(ctx, []chatMessage{
+		{Role: "system", Content: "You are a translator explaining your translations to a reader."},
+		{Role: "user", Content: explanationPrompt(fromLanguage, toLanguage, msg, translation)},
+	})
+}
+
+// complete sends the conversation to the chat completions endpoint and
+// returns the content of the first choice
+func (c *ChatClient) complete(ctx context.Context, messages []chatMessage) (string, error) {
+	headers := map[string]string{}
+	if c.apiKey != "" {
+		headers["Authorization"] = fmt.Sprintf("Bearer %s", c.apiKey)
+	}
+
+	var resp chatCompletionResponse
+	if err := postJSON(ctx, c.http, c.baseURL+"/chat/completions", headers, chatCompletionRequest{
+		Model:       c.model,
+		Messages:    messages,
+		Temperature: 0.3,
+		MaxTokens:   1024,
+	}, &resp); err != nil {
+		return "", errors.Wrap(err, "chat completions API error")
+	}
+
+	if len(resp.Choices) == 0 {
+		return "", errors.New("chat completions API returned no choices")
+	}
+
+	choice := resp.Choices[0]
+	if choice.FinishReason == "content_filter" {
+		return "", errors.New("chat completions API filtered the response")
+	}
+
+	return strings.TrimSpace(choice.Message.Content), nil
+}
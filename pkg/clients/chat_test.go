@@ -0,0 +1,127 @@
+/*
+ * File: chat_test.go
+ * Project: clients
+ * File Created: Saturday, 17th October 2026 7:19:35 am
+ * Author: Mark Mester (mmester6016@gmail.com)
+ * -----
+ * Last Modified: Saturday, 17th October 2026 7:19:35 am
+ * Modified By: Mark Mester (mmester6016@gmail.com>)
+ */
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// chatServer serves the chat completions endpoint with handler, recording
+// the requests it received
+func chatServer(t *testing.T, handler func(w http.ResponseWriter, req chatCompletionRequest)) (*httptest.Server, *[]*http.Request) {
+	t.Helper()
+
+	requests := []*http.Request{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+
+		var req chatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("invalid request body: %s", err)
+		}
+		handler(w, req)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestChatClientTranslate(t *testing.T) {
+	server, requests := chatServer(t, func(w http.ResponseWriter, req chatCompletionRequest) {
+		if req.Model != "test-model" {
+			t.Errorf("model = %q, want test-model", req.Model)
+		}
+		if len(req.Messages) != 2 || req.Messages[0].Role != "system" || req.Messages[1].Role != "user" {
+			t.Fatalf("unexpected messages %+v", req.Messages)
+		}
+		if !strings.Contains(req.Messages[1].Content, "Where is the station?") || !strings.Contains(req.Messages[1].Content, "Spanish") {
+			t.Errorf("prompt %q lacks the message or target language", req.Messages[1].Content)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id": "1", "model": "test-model", "choices": [{"index": 0, "message": {"role": "assistant", "content": " ¿Dónde está la estación?\n"}, "finish_reason": "stop"}]}`))
+	})
+
+	client := NewChatClient("secret", "test-model", server.URL+"/v1/")
+	translation, err := client.Translate(context.Background(), "English", "", "Spanish", "", "Where is the station?")
+	if err != nil {
+		t.Fatalf("Translate: %s", err)
+	}
+	if translation != "¿Dónde está la estación?" {
+		t.Errorf("translation = %q", translation)
+	}
+
+	if len(*requests) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(*requests))
+	}
+	req := (*requests)[0]
+	if auth := req.Header.Get("Authorization"); auth != "Bearer secret" {
+		t.Errorf("Authorization = %q, want Bearer secret", auth)
+	}
+	if contentType := req.Header.Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", contentType)
+	}
+}
+
+func TestChatClientWithoutAPIKey(t *testing.T) {
+	server, requests := chatServer(t, func(w http.ResponseWriter, req chatCompletionRequest) {
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "Hallo"}}]}`))
+	})
+
+	client := NewChatClient("", "", server.URL+"/v1")
+	if _, err := client.Translate(context.Background(), "English", "", "German", "", "Hello"); err != nil {
+		t.Fatalf("Translate: %s", err)
+	}
+	if auth := (*requests)[0].Header.Get("Authorization"); auth != "" {
+		t.Errorf("Authorization = %q, want none", auth)
+	}
+}
+
+func TestChatClientErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		err      string
+		attempts int
+	}{
+		{name: "unauthorized", status: http.StatusUnauthorized, body: `{"error": "invalid api key"}`, err: "unexpected status 401", attempts: 1},
+		{name: "no choices", status: http.StatusOK, body: `{"id": "1", "choices": []}`, err: "returned no choices", attempts: 1},
+		{name: "content filter", status: http.StatusOK, body: `{"choices": [{"message": {"content": ""}, "finish_reason": "content_filter"}]}`, err: "filtered", attempts: 1},
+		{name: "invalid json", status: http.StatusOK, body: `not json`, err: "invalid json response", attempts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := chatServer(t, func(w http.ResponseWriter, req chatCompletionRequest) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			})
+
+			client := NewChatClient("secret", "", server.URL+"/v1")
+			_, err := client.Translate(context.Background(), "English", "", "Spanish", "", "Hello")
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("Translate error = %v, want %q", err, tt.err)
+			}
+			if len(*requests) != tt.attempts {
+				t.Errorf("expected %d requests, got %d", tt.attempts, len(*requests))
+			}
+		})
+	}
+}
@@ -4,56 +4,272 @@
  * File Created: Saturday, 28th January 2023 10:46:32 am
  * Author: Mark Mester (mmester6016@gmail.com)
  * -----
- * Last Modified: Saturday, 28th January 2023 7:35:45 pm
+ * Last Modified: Saturday, 17th October 2026 7:20:36 am
  * Modified By: Mark Mester (mmester6016@gmail.com>)
  */
 package clients
 
 import (
+	"bytes"
 	"context"
+	stdErrors "errors"
+	"io"
+	"io/fs"
+	"net/http"
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
+	"sync"
 
-	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
 	"github.com/pkg/errors"
 )
 
+// KeySeparator separates the namespace of a datastore key from its name
+const KeySeparator = "/"
+
+// DataStore persists blobs under string keys. Keys may be namespaced (see
+// Key), e.g. "users/U0123", and listed by prefix.
 type DataStore interface {
 	Get(key string) (data []byte, err error)
 	Set(key string, data []byte) error
+	// Delete removes the key; deleting a missing key is not an error
+	Delete(key string) error
+	// List returns the keys starting with prefix in lexical order
+	List(prefix string) ([]string, error)
+	// Update applies the writes made to the batch by fn if fn returns nil.
+	// The batch is atomic where the backend supports it (bolt); otherwise its
+	// writes are applied in order and each is atomic on its own.
+	Update(fn func(batch Batch) error) error
+}
+
+// Batch collects the writes of a DataStore.Update
+type Batch interface {
+	Set(key string, data []byte)
+	Delete(key string)
+}
+
+// Key builds a namespaced datastore key, e.g. Key("users", "U0123")
+func Key(namespace string, parts ...string) string {
+	return strings.Join(append([]string{namespace}, parts...), KeySeparator)
 }
 
 // ============= S3 Client ============= //
 
+// ErrDatastoreConflict is returned when a write is rejected because the
+// stored data was changed by someone else since it was last read
+var ErrDatastoreConflict = errors.New("datastore object was modified concurrently")
+
+// DatastoreConfig holds optional settings of the datastore backends
+type DatastoreConfig struct {
+	// S3Endpoint overrides the S3 endpoint, e.g. to use an S3 compatible store
+	S3Endpoint string
+	// EncryptionKeys enables encryption at rest; see NewEncryptedStore
+	EncryptionKeys string
+}
+
 type S3Client struct {
 	*s3.Client
 
 	bucket string
+	prefix string
+
+	// etags of the objects as last read or written; an empty etag means
+	// the object didn't exist, and objects not in the map haven't been seen
+	mu    sync.Mutex
+	etags map[string]string
 }
 
-func NewS3Client(bucket string) (*S3Client, error) {
-	sdkConfig, err := config.LoadDefaultConfig(context.TODO())
+// NewS3Client creates a client storing objects under s3://<bucket>/<prefix>
+func NewS3Client(path string, config DatastoreConfig) (*S3Client, error) {
+	bucket, prefix, _ := strings.Cut(strings.TrimPrefix(path, "s3://"), "/")
+	if bucket == "" {
+		return nil, errors.Errorf("invalid s3 path '%s'; expected s3://<bucket>/<prefix>", path)
+	}
+
+	sdkConfig, err := awsConfig.LoadDefaultConfig(context.TODO())
 	if err != nil {
 		return nil, errors.Wrapf(err, "couldn't load default configuration")
 	}
 
+	client := s3.NewFromConfig(sdkConfig, func(o *s3.Options) {
+		if config.S3Endpoint != "" {
+			o.EndpointResolver = s3.EndpointResolverFromURL(config.S3Endpoint)
+			o.UsePathStyle = true
+			if o.Region == "" {
+				o.Region = "us-east-1"
+			}
+		}
+	})
+
 	return &S3Client{
-		Client: s3.NewFromConfig(sdkConfig),
+		Client: client,
 		bucket: bucket,
+		prefix: strings.Trim(prefix, "/"),
+		etags:  map[string]string{},
 	}, nil
+}
 
+func (s *S3Client) objectKey(key string) string {
+	if s.prefix == "" {
+		return key
+	}
+	return s.prefix + "/" + key
 }
 
 func (s *S3Client) Get(key string) (data []byte, err error) {
-	return nil, nil
+	out, err := s.GetObject(context.TODO(), &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.objectKey(key)),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if stdErrors.As(err, &noSuchKey) {
+			s.setETag(key, "")
+			return nil, os.ErrNotExist
+		}
+		return nil, errors.Wrapf(err, "failed to get s3://%s/%s", s.bucket, s.objectKey(key))
+	}
+	defer out.Body.Close()
+
+	data, err = io.ReadAll(out.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read s3://%s/%s", s.bucket, s.objectKey(key))
+	}
+
+	s.setETag(key, aws.ToString(out.ETag))
+	return data, nil
 }
 
+// Set writes the object, conditional on it being unchanged since it was last
+// read or written by this client so that two instances don't clobber each
+// other; an object this client hasn't seen is looked up first.
+// ErrDatastoreConflict is returned if it was changed; the object must be read
+// again before it can be written.
 func (s *S3Client) Set(key string, data []byte) error {
+	s.mu.Lock()
+	etag, seen := s.etags[key]
+	s.mu.Unlock()
+
+	// Objects this client never read, e.g. the installation of a workspace
+	// installed before, are replaced as they are now
+	if !seen {
+		var err error
+		if etag, err = s.headETag(key); err != nil {
+			return err
+		}
+		s.setETag(key, etag)
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(s.objectKey(key)),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+	}
+
+	condition := withHeader("If-None-Match", "*")
+	if etag != "" {
+		condition = withHeader("If-Match", etag)
+	}
+
+	out, err := s.PutObject(context.TODO(), input, condition)
+	if err != nil {
+		var respErr interface{ HTTPStatusCode() int }
+		if stdErrors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusPreconditionFailed {
+			return errors.Wrapf(ErrDatastoreConflict, "s3://%s/%s was changed by another writer", s.bucket, s.objectKey(key))
+		}
+		return errors.Wrapf(err, "failed to put s3://%s/%s", s.bucket, s.objectKey(key))
+	}
+
+	s.setETag(key, aws.ToString(out.ETag))
+	return nil
+}
+
+func (s *S3Client) Delete(key string) error {
+	if _, err := s.DeleteObject(context.TODO(), &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.objectKey(key)),
+	}); err != nil {
+		return errors.Wrapf(err, "failed to delete s3://%s/%s", s.bucket, s.objectKey(key))
+	}
+
+	s.setETag(key, "")
 	return nil
 }
 
+func (s *S3Client) List(prefix string) ([]string, error) {
+	keys := []string{}
+	paginator := s3.NewListObjectsV2Paginator(s.Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(s.objectKey(prefix)),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list s3://%s/%s", s.bucket, s.objectKey(prefix))
+		}
+		for _, object := range page.Contents {
+			key := aws.ToString(object.Key)
+			if s.prefix != "" {
+				key = strings.TrimPrefix(key, s.prefix+"/")
+			}
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (s *S3Client) Update(fn func(batch Batch) error) error {
+	return applyBatch(s, fn)
+}
+
+// headETag returns the current etag of the object, or "" if it doesn't exist
+func (s *S3Client) headETag(key string) (string, error) {
+	out, err := s.HeadObject(context.TODO(), &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.objectKey(key)),
+	})
+	if err != nil {
+		// HEAD responses have no body, so a missing object is only a 404
+		var respErr interface{ HTTPStatusCode() int }
+		if stdErrors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusNotFound {
+			return "", nil
+		}
+		return "", errors.Wrapf(err, "failed to head s3://%s/%s", s.bucket, s.objectKey(key))
+	}
+	return aws.ToString(out.ETag), nil
+}
+
+func (s *S3Client) setETag(key, etag string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.etags[key] = etag
+}
+
+// withHeader adds a header to the request, for conditions the SDK doesn't model
+func withHeader(name, value string) func(*s3.Options) {
+	return func(o *s3.Options) {
+		o.APIOptions = append(o.APIOptions, func(stack *middleware.Stack) error {
+			return stack.Build.Add(middleware.BuildMiddlewareFunc("Add"+name, func(
+				ctx context.Context, in middleware.BuildInput, next middleware.BuildHandler,
+			) (middleware.BuildOutput, middleware.Metadata, error) {
+				if req, ok := in.Request.(*smithyhttp.Request); ok {
+					req.Header.Set(name, value)
+				}
+				return next.HandleBuild(ctx, in)
+			}), middleware.After)
+		})
+	}
+}
+
 // ============= Local Client ============= //
 
 type LocalClient struct {
@@ -78,8 +294,71 @@ func (l *LocalClient) Get(key string) (data []byte, err error) {
 	return fileBytes, nil
 }
 
+// Set atomically replaces the file by writing a temporary file next to it and
+// renaming it into place, so a crash mid-write never leaves a partial file
 func (l *LocalClient) Set(key string, data []byte) error {
-	return os.WriteFile(key, data, 0744)
+	dir, name := path.Split(path.Join(l.path, key))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return errors.Wrapf(err, "unable to create directory for %s", key)
+	}
+
+	// Temporary files are only readable by the owner, which the renamed file keeps
+	tmp, err := os.CreateTemp(dir, "."+name+".*.tmp")
+	if err != nil {
+		return errors.Wrapf(err, "unable to create temporary file")
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return errors.Wrapf(err, "unable to write %s", key)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return errors.Wrapf(err, "unable to sync %s", key)
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrapf(err, "unable to close %s", key)
+	}
+	return os.Rename(tmp.Name(), path.Join(l.path, key))
+}
+
+func (l *LocalClient) Delete(key string) error {
+	if err := os.Remove(path.Join(l.path, key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// List walks the directory, mapping sub directories to key namespaces
+func (l *LocalClient) List(prefix string) ([]string, error) {
+	keys := []string{}
+	err := filepath.WalkDir(l.path, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Skip temporary files of in-progress writes
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			return nil
+		}
+
+		key, err := filepath.Rel(l.path, file)
+		if err != nil {
+			return err
+		}
+		if key = filepath.ToSlash(key); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list %s", l.path)
+	}
+	return keys, nil
+}
+
+func (l *LocalClient) Update(fn func(batch Batch) error) error {
+	return applyBatch(l, fn)
 }
 
 // ============= NooP Client ============= //
@@ -94,11 +373,37 @@ func (n *NooPClient) Set(_ string, _ []byte) error {
 	return nil
 }
 
-func NewDatastore(path string) (DataStore, error) {
+func (n *NooPClient) Delete(_ string) error {
+	return nil
+}
+
+func (n *NooPClient) List(_ string) ([]string, error) {
+	return []string{}, nil
+}
+
+func (n *NooPClient) Update(fn func(batch Batch) error) error {
+	return fn(&writeBatch{})
+}
+
+func NewDatastore(path string, config DatastoreConfig) (DataStore, error) {
+	store, err := newBackend(path, config)
+	if err != nil || config.EncryptionKeys == "" {
+		return store, err
+	}
+
+	return NewEncryptedStore(store, config.EncryptionKeys)
+}
+
+func newBackend(path string, config DatastoreConfig) (DataStore, error) {
 
 	// S3 path?
 	if strings.HasPrefix(path, "s3://") {
-		return NewS3Client(strings.TrimPrefix(path, "s3://"))
+		return NewS3Client(path, config)
+	}
+
+	// Embedded database?
+	if strings.HasPrefix(path, "bolt://") {
+		return NewBoltClient(strings.TrimPrefix(path, "bolt://"))
 	}
 
 	// Local path?
@@ -109,3 +414,45 @@ func NewDatastore(path string) (DataStore, error) {
 	// Default to in memory
 	return &NooPClient{}, nil
 }
+
+// =========== Helpers ================ //
+
+type batchOp struct {
+	key    string
+	data   []byte
+	delete bool
+}
+
+// writeBatch records the writes of a batch for backends without transactions
+type writeBatch struct {
+	ops []batchOp
+}
+
+func (w *writeBatch) Set(key string, data []byte) {
+	w.ops = append(w.ops, batchOp{key: key, data: data})
+}
+
+func (w *writeBatch) Delete(key string) {
+	w.ops = append(w.ops, batchOp{key: key, delete: true})
+}
+
+// applyBatch runs fn and applies the writes it made to the store in order
+func applyBatch(store DataStore, fn func(batch Batch) error) error {
+	batch := &writeBatch{}
+	if err := fn(batch); err != nil {
+		return err
+	}
+
+	for _, op := range batch.ops {
+		if op.delete {
+			if err := store.Delete(op.key); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := store.Set(op.key, op.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
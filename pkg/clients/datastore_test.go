@@ -0,0 +1,303 @@
+/*
+ * File: datastore_test.go
+ * Project: clients
+ * File Created: Saturday, 17th October 2026 7:20:36 am
+ * Author: Mark Mester (mmester6016@gmail.com)
+ * -----
+ * Last Modified: Saturday, 17th October 2026 7:20:36 am
+ * Modified By: Mark Mester (mmester6016@gmail.com>)
+ */
+package clients
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// fakeS3 is an in-memory stand-in for an S3 compatible store, serving
+// path-style requests for a single bucket
+type fakeS3 struct {
+	mu      sync.Mutex
+	bucket  string
+	objects map[string][]byte
+	// puts are the conditional headers of each put, e.g. "If-Match: <etag>"
+	puts []string
+}
+
+func newFakeS3(t *testing.T, bucket string) (*fakeS3, *httptest.Server) {
+	t.Helper()
+
+	f := &fakeS3{bucket: bucket, objects: map[string][]byte{}}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	return f, server
+}
+
+func fakeETag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != f.bucket {
+		f.error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	data, exists := f.objects[key]
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		f.list(w, r.URL.Query().Get("prefix"))
+
+	case r.Method == http.MethodGet:
+		if !exists {
+			f.error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("ETag", fakeETag(data))
+		w.Write(data)
+
+	case r.Method == http.MethodHead:
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", fakeETag(data))
+
+	case r.Method == http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		ifMatch, ifNoneMatch := r.Header.Get("If-Match"), r.Header.Get("If-None-Match")
+		switch {
+		case ifMatch != "":
+			f.puts = append(f.puts, "If-Match: "+ifMatch)
+		case ifNoneMatch != "":
+			f.puts = append(f.puts, "If-None-Match: "+ifNoneMatch)
+		default:
+			f.puts = append(f.puts, "")
+		}
+
+		if (ifMatch != "" && (!exists || ifMatch != fakeETag(data))) || (ifNoneMatch == "*" && exists) {
+			f.error(w, http.StatusPreconditionFailed, "PreconditionFailed")
+			return
+		}
+		f.objects[key] = body
+		w.Header().Set("ETag", fakeETag(body))
+
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		f.error(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, prefix string) {
+	type object struct {
+		Key  string `xml:"Key"`
+		ETag string `xml:"ETag"`
+		Size int    `xml:"Size"`
+	}
+	result := struct {
+		XMLName     xml.Name `xml:"ListBucketResult"`
+		Name        string   `xml:"Name"`
+		Prefix      string   `xml:"Prefix"`
+		KeyCount    int      `xml:"KeyCount"`
+		IsTruncated bool     `xml:"IsTruncated"`
+		Contents    []object `xml:"Contents"`
+	}{Name: f.bucket, Prefix: prefix}
+
+	keys := []string{}
+	for key := range f.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		result.Contents = append(result.Contents, object{Key: key, ETag: fakeETag(f.objects[key]), Size: len(f.objects[key])})
+	}
+	result.KeyCount = len(keys)
+
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(result)
+}
+
+func (f *fakeS3) error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+// put stores an object as another writer would
+func (f *fakeS3) put(key, data string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.objects[key] = []byte(data)
+}
+
+func (f *fakeS3) has(key string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	_, ok := f.objects[key]
+	return ok
+}
+
+// takePuts returns and clears the conditions of the puts made so far
+func (f *fakeS3) takePuts() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	puts := f.puts
+	f.puts = nil
+	return puts
+}
+
+func newTestS3Client(t *testing.T, path, endpoint string) *S3Client {
+	t.Helper()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_CONFIG_FILE", os.DevNull)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", os.DevNull)
+
+	client, err := NewS3Client(path, DatastoreConfig{S3Endpoint: endpoint})
+	if err != nil {
+		t.Fatalf("NewS3Client: %s", err)
+	}
+	return client
+}
+
+func TestS3ClientGetMissing(t *testing.T) {
+	_, server := newFakeS3(t, "bucket")
+	client := newTestS3Client(t, "s3://bucket/prefix", server.URL)
+
+	if _, err := client.Get("store.json"); !os.IsNotExist(err) {
+		t.Fatalf("Get of missing key: err = %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestS3ClientConditionalWrites(t *testing.T) {
+	fake, server := newFakeS3(t, "bucket")
+	client := newTestS3Client(t, "s3://bucket/prefix", server.URL)
+
+	// A key read as missing must still not exist when written
+	if _, err := client.Get("store.json"); !os.IsNotExist(err) {
+		t.Fatalf("Get: %v", err)
+	}
+	if err := client.Set("store.json", []byte("v1")); err != nil {
+		t.Fatalf("Set: %s", err)
+	}
+	// Further writes are conditional on the etag written
+	if err := client.Set("store.json", []byte("v2")); err != nil {
+		t.Fatalf("Set: %s", err)
+	}
+	want := []string{"If-None-Match: *", "If-Match: " + fakeETag([]byte("v1"))}
+	if puts := fake.takePuts(); !reflect.DeepEqual(puts, want) {
+		t.Errorf("conditions = %q, want %q", puts, want)
+	}
+
+	// Another writer changes the object
+	fake.put("prefix/store.json", "other")
+
+	err := client.Set("store.json", []byte("v3"))
+	if errors.Cause(err) != ErrDatastoreConflict {
+		t.Fatalf("Set after concurrent change: err = %v, want ErrDatastoreConflict", err)
+	}
+	// The conflict persists until the object is read again
+	if err := client.Set("store.json", []byte("v3")); errors.Cause(err) != ErrDatastoreConflict {
+		t.Fatalf("retried Set after concurrent change: err = %v, want ErrDatastoreConflict", err)
+	}
+	if data, err := client.Get("store.json"); err != nil || string(data) != "other" {
+		t.Fatalf("Get = %q, %v; want the other writer's data", data, err)
+	}
+	if err := client.Set("store.json", []byte("v3")); err != nil {
+		t.Fatalf("Set after reading: %s", err)
+	}
+}
+
+func TestS3ClientConflictOnCreate(t *testing.T) {
+	fake, server := newFakeS3(t, "bucket")
+	client := newTestS3Client(t, "s3://bucket", server.URL)
+
+	if _, err := client.Get("store.json"); !os.IsNotExist(err) {
+		t.Fatalf("Get: %v", err)
+	}
+	fake.put("store.json", "other")
+
+	if err := client.Set("store.json", []byte("v1")); errors.Cause(err) != ErrDatastoreConflict {
+		t.Fatalf("Set of key created concurrently: err = %v, want ErrDatastoreConflict", err)
+	}
+}
+
+func TestS3ClientWriteUnseenKey(t *testing.T) {
+	fake, server := newFakeS3(t, "bucket")
+	client := newTestS3Client(t, "s3://bucket", server.URL)
+
+	// e.g. reinstalling a workspace whose installation this client never read
+	fake.put("teams/T1", "old")
+	if err := client.Set("teams/T1", []byte("new")); err != nil {
+		t.Fatalf("Set of unseen existing key: %s", err)
+	}
+	if err := client.Set("teams/T2", []byte("new")); err != nil {
+		t.Fatalf("Set of unseen new key: %s", err)
+	}
+
+	want := []string{"If-Match: " + fakeETag([]byte("old")), "If-None-Match: *"}
+	if puts := fake.takePuts(); !reflect.DeepEqual(puts, want) {
+		t.Errorf("conditions = %q, want %q", puts, want)
+	}
+}
+
+func TestS3ClientPrefix(t *testing.T) {
+	fake, server := newFakeS3(t, "bucket")
+	client := newTestS3Client(t, "s3://bucket/fanyi/", server.URL)
+
+	for _, key := range []string{"store.json", "teams/T1", "teams/T2"} {
+		if err := client.Set(key, []byte(key)); err != nil {
+			t.Fatalf("Set %s: %s", key, err)
+		}
+	}
+	fake.put("other/teams/T3", "not ours")
+
+	keys, err := client.List("teams/")
+	if err != nil {
+		t.Fatalf("List: %s", err)
+	}
+	if want := []string{"teams/T1", "teams/T2"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("List = %q, want %q", keys, want)
+	}
+
+	if err := client.Delete("teams/T1"); err != nil {
+		t.Fatalf("Delete: %s", err)
+	}
+	if fake.has("fanyi/teams/T1") {
+		t.Errorf("fanyi/teams/T1 was not deleted")
+	}
+	if keys, _ = client.List(""); !reflect.DeepEqual(keys, []string{"store.json", "teams/T2"}) {
+		t.Errorf("List after delete = %q", keys)
+	}
+	if _, err := client.Get("teams/T1"); !os.IsNotExist(err) {
+		t.Errorf("Get of deleted key: err = %v, want os.ErrNotExist", err)
+	}
+}
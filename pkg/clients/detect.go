@@ -4,17 +4,18 @@
  * File Created: Thursday, 26th January 2023 11:41:18 am
  * Author: Mark Mester (mmester6016@gmail.com)
  * -----
- * Last Modified: Saturday, 28th January 2023 4:15:49 pm
+ * Last Modified: Saturday, 17th October 2026 8:02:07 am
  * Modified By: Mark Mester (mmester6016@gmail.com>)
  */
 package clients
 
 import (
-	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"sort"
 	"strings"
+	"sync"
 
 	"github.com/pemistahl/lingua-go"
 )
@@ -31,11 +32,19 @@ var commonLanguages = []lingua.Language{
 }
 
 type Channel string
+
+// Detector is safe for concurrent use. Select detectors are never modified
+// once added to SelectDetectors; updates replace them, so a select detector
+// returned by GetSelectedDetector can be used without locking.
 type Detector struct {
 	linguaAllLanguages    lingua.LanguageDetector `json:"-"`
 	linguaCommonLanguages lingua.LanguageDetector `json:"-"`
 
+	// mu guards SelectDetectors and onChange
+	mu              sync.RWMutex
 	SelectDetectors map[Channel]*SelectDetector `json:"select_detectors"` // -> maps channel to select detector
+
+	onChange func(channel string)
 }
 
 type SelectDetector struct {
@@ -43,9 +52,13 @@ type SelectDetector struct {
 	Selected              *Selected               `json:"selected"`
 }
 
+// Selected holds the languages a channel is auto-translated between;
+// messages in one of them are translated into all others
 type Selected struct {
-	L1 lingua.Language `json:"l1"`
-	L2 lingua.Language `json:"l2"`
+	Languages []lingua.Language `json:"languages"`
+
+	// Optional dialects of the languages (e.g. "Wuhan" for Chinese)
+	Dialects map[lingua.Language]string `json:"dialects,omitempty"`
 }
 
 func NewDetector() *Detector {
@@ -57,29 +70,6 @@ func NewDetector() *Detector {
 	}
 }
 
-func (d *Detector) ToJSON() ([]byte, error) {
-	return json.Marshal(d)
-}
-
-func (d *Detector) FromJSON(jsonBytes []byte) error {
-	var detector Detector
-	if err := json.Unmarshal(jsonBytes, &detector); err != nil {
-		return err
-	}
-
-	for channel, selectDetector := range detector.SelectDetectors {
-		if _, err := d.UpdateSelected(
-			string(channel),
-			selectDetector.Selected.L1.String(),
-			selectDetector.Selected.L2.String(),
-		); err != nil {
-			return err
-		}
-	}
-
-	return nil
-}
-
 // Detect returns a best attempt at determining the input language.
 // If the language can't be reliably detected, false is returned.
 func (d *Detector) Detect(text string, threshold ...float32) (string, bool) {
@@ -104,41 +94,167 @@ func (d *Detector) Detect(text string, threshold ...float32) (string, bool) {
 	return language.String(), true
 }
 
+// OnChange sets a function called whenever the selection of a channel
+// changes, e.g. to persist it. It is called without holding the lock.
+func (d *Detector) OnChange(fn func(channel string)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.onChange = fn
+}
+
+// changed calls the OnChange function, if any
+func (d *Detector) changed(channel string) {
+	d.mu.RLock()
+	fn := d.onChange
+	d.mu.RUnlock()
+
+	if fn != nil {
+		fn(channel)
+	}
+}
+
 func (d *Detector) ClearSelected(channel string) {
+	d.mu.Lock()
+	_, ok := d.SelectDetectors[Channel(channel)]
 	delete(d.SelectDetectors, Channel(channel))
-}
+	d.mu.Unlock()
 
-func (d *Detector) UpdateSelected(channel, l1, l2 string) (bool, error) {
-	// Determine language choices
-	l1Lang := stringToLang(l1)
-	l2Lang := stringToLang(l2)
+	if ok {
+		d.changed(channel)
+	}
+}
 
-	if l1Lang == lingua.Unknown || l2Lang == lingua.Unknown {
-		return false, errors.New("unknown language selected")
+// UpdateSelected sets the languages the channel is auto-translated between;
+// at least two distinct languages are required
+func (d *Detector) UpdateSelected(channel string, languages ...string) (bool, error) {
+	ok, err := d.updateSelected(channel, languages...)
+	if ok {
+		d.changed(channel)
 	}
+	return ok, err
+}
 
-	// Retrieve select detector
-	selectDetector, _ := d.GetSelectedDetector(channel)
-	if selectDetector == nil {
-		selectDetector = &SelectDetector{}
+func (d *Detector) updateSelected(channel string, languages ...string) (bool, error) {
+	// Determine language choices
+	langs := []lingua.Language{}
+	for _, language := range languages {
+		lang := stringToLang(language)
+		if lang == lingua.Unknown {
+			return false, fmt.Errorf("unknown language '%s' selected", language)
+		}
+		if containsLang(langs, lang) {
+			return false, fmt.Errorf("%s selected more than once", lang)
+		}
+		langs = append(langs, lang)
+	}
+	if len(langs) < 2 {
+		return false, errors.New("at least 2 languages must be selected")
 	}
 
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
 	// Update?
-	if selectDetector.Selected == nil || *selectDetector.Selected != (Selected{L1: l1Lang, L2: l2Lang}) {
-		log.Printf("Reconfiguring select detector for %s:%s", l1Lang, l2Lang)
-		selectDetector.linguaSelectLanguages =
-			lingua.NewLanguageDetectorBuilder().FromLanguages([]lingua.Language{l1Lang, l2Lang}...).WithPreloadedLanguageModels().Build()
-		selectDetector.Selected = &Selected{L1: l1Lang, L2: l2Lang}
+	current := d.SelectDetectors[Channel(channel)]
+	if current != nil && sameLangs(current.Selected.Languages, langs) {
+		return false, nil
+	}
 
-		d.SelectDetectors[Channel(channel)] = selectDetector
+	// Languages that remain selected keep their dialect
+	selected := &Selected{Languages: langs}
+	if current != nil {
+		for lang, dialect := range current.Selected.Dialects {
+			if !containsLang(langs, lang) {
+				continue
+			}
+			if selected.Dialects == nil {
+				selected.Dialects = map[lingua.Language]string{}
+			}
+			selected.Dialects[lang] = dialect
+		}
+	}
 
+	// Reordering the languages doesn't change what is detected
+	if current != nil && sameLangSet(current.Selected.Languages, langs) {
+		d.SelectDetectors[Channel(channel)] = &SelectDetector{
+			linguaSelectLanguages: current.linguaSelectLanguages,
+			Selected:              selected,
+		}
 		return true, nil
 	}
 
-	return false, nil
+	log.Printf("Reconfiguring select detector for %s", langs)
+	d.SelectDetectors[Channel(channel)] = &SelectDetector{
+		linguaSelectLanguages: lingua.NewLanguageDetectorBuilder().FromLanguages(langs...).WithPreloadedLanguageModels().Build(),
+		Selected:              selected,
+	}
+
+	return true, nil
+}
+
+// UpdateDialect sets the dialect used for one of the channel's selected
+// languages; an empty dialect clears it
+func (d *Detector) UpdateDialect(channel, language, dialect string) (bool, error) {
+	ok, err := d.updateDialect(channel, language, dialect)
+	if ok {
+		d.changed(channel)
+	}
+	return ok, err
 }
 
+func (d *Detector) updateDialect(channel, language, dialect string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	selectDetector, ok := d.SelectDetectors[Channel(channel)]
+	if !ok {
+		return false, errors.New("channel not initialized for select detection")
+	}
+
+	lang := stringToLang(language)
+	dialect = strings.TrimSpace(dialect)
+
+	current := selectDetector.Selected
+	if !containsLang(current.Languages, lang) {
+		return false, fmt.Errorf("%s is not selected for auto-translation", language)
+	}
+	if current.Dialects[lang] == dialect {
+		return false, nil
+	}
+
+	// Copy on write; readers may hold the current selection
+	selected := Selected{Languages: current.Languages, Dialects: map[lingua.Language]string{}}
+	for l, existing := range current.Dialects {
+		selected.Dialects[l] = existing
+	}
+	if dialect == "" {
+		delete(selected.Dialects, lang)
+	} else {
+		selected.Dialects[lang] = dialect
+	}
+
+	d.SelectDetectors[Channel(channel)] = &SelectDetector{
+		linguaSelectLanguages: selectDetector.linguaSelectLanguages,
+		Selected:              &selected,
+	}
+	return true, nil
+}
+
+// GetDialect returns the dialect configured for language in the channel, if any
+func (d *Detector) GetDialect(channel, language string) string {
+	selectDetector, err := d.GetSelectedDetector(channel)
+	if err != nil {
+		return ""
+	}
+	return selectDetector.Selected.Dialect(stringToLang(language))
+}
+
+// GetSelectedDetector returns the channel's select detector, which must not be modified
 func (d *Detector) GetSelectedDetector(channel string) (*SelectDetector, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
 	selectDetector, ok := d.SelectDetectors[Channel(channel)]
 	if !ok {
 		return nil, errors.New("channel not initialized for select detection")
@@ -146,6 +262,28 @@ func (d *Detector) GetSelectedDetector(channel string) (*SelectDetector, error)
 	return selectDetector, nil
 }
 
+// Snapshot returns a copy of the selections of all channels
+func (d *Detector) Snapshot() map[Channel]Selected {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	snapshot := make(map[Channel]Selected, len(d.SelectDetectors))
+	for channel, selectDetector := range d.SelectDetectors {
+		snapshot[channel] = *selectDetector.Selected
+	}
+	return snapshot
+}
+
+// SupportedLanguages returns the names of all languages that can be detected, sorted
+func SupportedLanguages() []string {
+	names := []string{}
+	for _, l := range lingua.AllLanguages() {
+		names = append(names, l.String())
+	}
+	sort.Strings(names)
+	return names
+}
+
 // =========== Select Detector ============== //
 
 func (s *SelectDetector) Select(channel, text string) (lingua.Language, error) {
@@ -158,6 +296,33 @@ func (s *SelectDetector) Select(channel, text string) (lingua.Language, error) {
 	return confidences[len(confidences)-1].Language(), nil
 }
 
+// =========== Selected ============== //
+
+// Dialect returns the dialect selected for language, if any
+func (s *Selected) Dialect(language lingua.Language) string {
+	return s.Dialects[language]
+}
+
+// Targets returns the selected languages a message in language is translated into
+func (s *Selected) Targets(language lingua.Language) []lingua.Language {
+	targets := []lingua.Language{}
+	for _, l := range s.Languages {
+		if l != language {
+			targets = append(targets, l)
+		}
+	}
+	return targets
+}
+
+// String lists the selected languages, e.g. "English <-> Spanish"
+func (s *Selected) String() string {
+	names := []string{}
+	for _, l := range s.Languages {
+		names = append(names, l.String())
+	}
+	return strings.Join(names, " <-> ")
+}
+
 // =========== Helpers ================ //
 
 func stringToLang(str string) lingua.Language {
@@ -168,3 +333,38 @@ func stringToLang(str string) lingua.Language {
 	}
 	return lingua.Unknown
 }
+
+func containsLang(langs []lingua.Language, lang lingua.Language) bool {
+	for _, l := range langs {
+		if l == lang {
+			return true
+		}
+	}
+	return false
+}
+
+// sameLangSet reports whether a and b hold the same languages in any order
+func sameLangSet(a, b []lingua.Language) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, lang := range a {
+		if !containsLang(b, lang) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameLangs reports whether a and b hold the same languages in the same order
+func sameLangs(a, b []lingua.Language) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
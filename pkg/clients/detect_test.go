@@ -0,0 +1,144 @@
+/*
+ * File: detect_test.go
+ * Project: clients
+ * File Created: Saturday, 17th October 2026 7:01:21 am
+ * Author: Mark Mester (mmester6016@gmail.com)
+ * -----
+ * Last Modified: Saturday, 17th October 2026 8:02:07 am
+ * Modified By: Mark Mester (mmester6016@gmail.com>)
+ */
+package clients
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/pemistahl/lingua-go"
+)
+
+// newSelectDetector returns a detector for channels' selected languages only.
+// NewDetector preloads the models of every language for Detect, which takes
+// minutes under the race detector.
+func newSelectDetector() *Detector {
+	return &Detector{SelectDetectors: map[Channel]*SelectDetector{}}
+}
+
+// TestDetectorConcurrentUse exercises the Detector from many goroutines;
+// run with `go test -race` to check its locking
+func TestDetectorConcurrentUse(t *testing.T) {
+	d := newSelectDetector()
+
+	// Building a lingua detector is slow, so each channel only reorders its
+	// languages, which reuses the channel's detector until it is cleared
+	selections := map[string][][]string{
+		"C1": {{"English", "Spanish", "German"}, {"German", "English", "Spanish"}, {"Spanish", "German", "English"}},
+		"C2": {{"Chinese", "English"}, {"English", "Chinese"}},
+	}
+	const iterations = 20
+
+	var wg sync.WaitGroup
+	run := func(fn func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				fn(i)
+			}
+		}()
+	}
+
+	for channel, selections := range selections {
+		channel, selections := channel, selections
+		run(func(i int) {
+			if _, err := d.UpdateSelected(channel, selections[i%len(selections)]...); err != nil {
+				t.Errorf("UpdateSelected: %s", err)
+			}
+		})
+		run(func(i int) {
+			// The language may not be selected at the time; only races matter
+			d.UpdateDialect(channel, "English", []string{"British", "American", ""}[i%3])
+		})
+		run(func(i int) {
+			if i == iterations/2 {
+				d.ClearSelected(channel)
+			}
+		})
+		run(func(i int) {
+			selectDetector, err := d.GetSelectedDetector(channel)
+			if err != nil {
+				return
+			}
+			language, err := selectDetector.Select(channel, "Where is the train station?")
+			if err != nil {
+				t.Errorf("Select: %s", err)
+			}
+			if !containsLang(selectDetector.Selected.Languages, language) {
+				t.Errorf("Select returned %s, which is not one of %s", language, selectDetector.Selected)
+			}
+		})
+	}
+
+	run(func(i int) {
+		checkSnapshot(t, d.Snapshot())
+	})
+
+	wg.Wait()
+}
+
+// checkSnapshot asserts a snapshot of the detector is internally consistent:
+// each channel has at least two distinct languages, and only those have dialects
+func checkSnapshot(t *testing.T, snapshot map[Channel]Selected) {
+	t.Helper()
+
+	for channel, selected := range snapshot {
+		if len(selected.Languages) < 2 {
+			t.Errorf("channel %s: expected at least 2 languages, got %s", channel, selected)
+		}
+		seen := map[lingua.Language]bool{}
+		for _, language := range selected.Languages {
+			if seen[language] {
+				t.Errorf("channel %s: %s selected more than once", channel, language)
+			}
+			seen[language] = true
+		}
+		for language := range selected.Dialects {
+			if !seen[language] {
+				t.Errorf("channel %s: dialect of unselected language %s", channel, language)
+			}
+		}
+	}
+}
+
+func TestUpdateSelectedKeepsDialects(t *testing.T) {
+	d := newSelectDetector()
+
+	if _, err := d.UpdateSelected("C1", "English", "Spanish", "German"); err != nil {
+		t.Fatalf("UpdateSelected: %s", err)
+	}
+	for language, dialect := range map[string]string{"English": "British", "Spanish": "Mexican"} {
+		if _, err := d.UpdateDialect("C1", language, dialect); err != nil {
+			t.Fatalf("UpdateDialect: %s", err)
+		}
+	}
+
+	// Reordered
+	if ok, err := d.UpdateSelected("C1", "German", "Spanish", "English"); err != nil || !ok {
+		t.Fatalf("UpdateSelected reordered = %t, %v", ok, err)
+	}
+	if got := d.GetDialect("C1", "English"); got != "British" {
+		t.Errorf("English dialect after reorder = %q, want British", got)
+	}
+
+	// Spanish deselected
+	if _, err := d.UpdateSelected("C1", "German", "English", "French"); err != nil {
+		t.Fatalf("UpdateSelected: %s", err)
+	}
+	if got := d.GetDialect("C1", "English"); got != "British" {
+		t.Errorf("English dialect after change = %q, want British", got)
+	}
+	if got := d.GetDialect("C1", "Spanish"); got != "" {
+		t.Errorf("dialect of deselected Spanish = %q, want none", got)
+	}
+
+	checkSnapshot(t, d.Snapshot())
+}
@@ -0,0 +1,247 @@
+/*
+ * File: encrypt.go
+ * Project: clients
+ * File Created: Saturday, 17th October 2026 6:31:10 am
+ * Author: Mark Mester (mmester6016@gmail.com)
+ * -----
+ * Last Modified: Saturday, 17th October 2026 7:04:55 am
+ * Modified By: Mark Mester (mmester6016@gmail.com>)
+ */
+package clients
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// encryptedMagic prefixes every blob written by EncryptedStore. It is
+// followed by the length of the key ID, the key ID, the nonce and the
+// AES-GCM sealed data.
+var encryptedMagic = []byte("FNYENC1")
+
+// ErrDecryption is returned when a blob can't be decrypted with the configured keys
+var ErrDecryption = errors.New("unable to decrypt datastore object; is the encryption key correct?")
+
+// EncryptedStore encrypts the blobs of any DataStore with AES-GCM. Blobs
+// encrypted with an older key are re-encrypted with the current key when read,
+// so keys can be rotated by adding a new current key while keeping the old one
+// until everything has been read. Unencrypted blobs are rejected with
+// ErrDecryption; existing data is encrypted explicitly with EncryptPlaintext.
+type EncryptedStore struct {
+	DataStore
+
+	// current is the ID of the key new blobs are encrypted with
+	current string
+	keys    map[string]cipher.AEAD
+}
+
+// NewEncryptedStore wraps store, encrypting with the first of the given keys.
+// Keys are given as "<id>:<base64 key>" pairs separated by commas or
+// newlines; keys must be 16, 24 or 32 bytes.
+func NewEncryptedStore(store DataStore, keys string) (*EncryptedStore, error) {
+	e := &EncryptedStore{DataStore: store, keys: map[string]cipher.AEAD{}}
+
+	for _, entry := range strings.FieldsFunc(keys, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if entry = strings.TrimSpace(entry); entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		id, encoded, found := strings.Cut(entry, ":")
+		if !found || id == "" || len(id) > 255 {
+			return nil, errors.New("encryption keys must be given as <id>:<base64 key>")
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, errors.Wrapf(err, "encryption key '%s' is not valid base64", id)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid encryption key '%s'", id)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, exists := e.keys[id]; exists {
+			return nil, fmt.Errorf("duplicate encryption key '%s'", id)
+		}
+		if e.current == "" {
+			e.current = id
+		}
+		e.keys[id] = aead
+	}
+
+	if e.current == "" {
+		return nil, errors.New("no encryption key given")
+	}
+	return e, nil
+}
+
+func (e *EncryptedStore) Get(key string) (data []byte, err error) {
+	blob, err := e.DataStore.Get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.HasPrefix(blob, encryptedMagic) {
+		return nil, errors.Wrapf(ErrDecryption, "%s is not encrypted (run `fanyi config encrypt` to encrypt existing data)", key)
+	}
+
+	id, data, err := e.decrypt(key, blob)
+	if err != nil {
+		return nil, err
+	}
+
+	if id != e.current {
+		log.Printf("Re-encrypting datastore object %s with key %s", key, e.current)
+		if err := e.Set(key, data); err != nil {
+			return nil, errors.Wrapf(err, "unable to re-encrypt %s", key)
+		}
+	}
+	return data, nil
+}
+
+func (e *EncryptedStore) Set(key string, data []byte) error {
+	blob, err := e.encrypt(key, data)
+	if err != nil {
+		return err
+	}
+	return e.DataStore.Set(key, blob)
+}
+
+func (e *EncryptedStore) Update(fn func(batch Batch) error) error {
+	return e.DataStore.Update(func(batch Batch) error {
+		encrypted := &encryptedBatch{Batch: batch, store: e}
+		if err := fn(encrypted); err != nil {
+			return err
+		}
+		return encrypted.err
+	})
+}
+
+// EncryptPlaintext encrypts every unencrypted blob of the wrapped store,
+// returning the keys it encrypted. Run it once when enabling encryption for
+// an existing datastore; the store must not be written to meanwhile.
+func (e *EncryptedStore) EncryptPlaintext() ([]string, error) {
+	keys, err := e.DataStore.List("")
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted := []string{}
+	for _, key := range keys {
+		blob, err := e.DataStore.Get(key)
+		if err != nil {
+			return encrypted, err
+		}
+		if bytes.HasPrefix(blob, encryptedMagic) {
+			continue
+		}
+		if err := e.Set(key, blob); err != nil {
+			return encrypted, errors.Wrapf(err, "unable to encrypt %s", key)
+		}
+		encrypted = append(encrypted, key)
+	}
+	return encrypted, nil
+}
+
+// Close closes the wrapped store if it needs closing
+func (e *EncryptedStore) Close() error {
+	if closer, ok := e.DataStore.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// encrypt seals data with the current key; the datastore key is
+// authenticated so blobs can't be swapped between keys
+func (e *EncryptedStore) encrypt(key string, data []byte) ([]byte, error) {
+	aead := e.keys[e.current]
+
+	header := append(append([]byte{}, encryptedMagic...), byte(len(e.current)))
+	header = append(header, e.current...)
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrapf(err, "unable to generate nonce")
+	}
+
+	blob := append(header, nonce...)
+	return aead.Seal(blob, nonce, data, additionalData(header, key)), nil
+}
+
+// decrypt opens a blob, returning the ID of the key it was encrypted with.
+// It fails closed: any blob that can't be authenticated is an error.
+func (e *EncryptedStore) decrypt(key string, blob []byte) (string, []byte, error) {
+	rest := blob[len(encryptedMagic):]
+	if len(rest) < 1 || len(rest) < 1+int(rest[0]) {
+		return "", nil, errors.Wrapf(ErrDecryption, "%s has a malformed header", key)
+	}
+
+	id := string(rest[1 : 1+int(rest[0])])
+	header := blob[:len(encryptedMagic)+1+len(id)]
+	rest = rest[1+len(id):]
+
+	aead, ok := e.keys[id]
+	if !ok {
+		return "", nil, errors.Wrapf(ErrDecryption, "%s was encrypted with unknown key '%s'", key, id)
+	}
+	if len(rest) < aead.NonceSize() {
+		return "", nil, errors.Wrapf(ErrDecryption, "%s is truncated", key)
+	}
+
+	data, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], additionalData(header, key))
+	if err != nil {
+		return "", nil, errors.Wrapf(ErrDecryption, "%s could not be decrypted with key '%s'", key, id)
+	}
+	return id, data, nil
+}
+
+type encryptedBatch struct {
+	Batch
+
+	store *EncryptedStore
+	err   error
+}
+
+func (b *encryptedBatch) Set(key string, data []byte) {
+	if b.err != nil {
+		return
+	}
+	blob, err := b.store.encrypt(key, data)
+	if err != nil {
+		b.err = err
+		return
+	}
+	b.Batch.Set(key, blob)
+}
+
+// =========== Helpers ================ //
+
+func additionalData(header []byte, key string) []byte {
+	return append(append([]byte{}, header...), key...)
+}
+
+// LoadEncryptionKeys returns the keys given directly, or read from keyFile
+func LoadEncryptionKeys(keys, keyFile string) (string, error) {
+	if keys != "" || keyFile == "" {
+		return keys, nil
+	}
+
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to read encryption key file")
+	}
+	return string(data), nil
+}
@@ -0,0 +1,231 @@
+/*
+ * File: encrypt_test.go
+ * Project: clients
+ * File Created: Saturday, 17th October 2026 7:21:08 am
+ * Author: Mark Mester (mmester6016@gmail.com)
+ * -----
+ * Last Modified: Saturday, 17th October 2026 7:21:08 am
+ * Modified By: Mark Mester (mmester6016@gmail.com>)
+ */
+package clients
+
+import (
+	"bytes"
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func testKey(id string, fill byte) string {
+	return id + ":" + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{fill}, 32))
+}
+
+func newTestEncryptedStore(t *testing.T, store DataStore, keys ...string) *EncryptedStore {
+	t.Helper()
+
+	encrypted, err := NewEncryptedStore(store, strings.Join(keys, ","))
+	if err != nil {
+		t.Fatalf("NewEncryptedStore: %s", err)
+	}
+	return encrypted
+}
+
+func newTestLocalClient(t *testing.T) *LocalClient {
+	t.Helper()
+
+	local, err := NewLocalClient(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalClient: %s", err)
+	}
+	return local
+}
+
+// keyID returns the ID of the key a stored blob was encrypted with
+func keyID(t *testing.T, store DataStore, key string) string {
+	t.Helper()
+
+	blob, err := store.Get(key)
+	if err != nil {
+		t.Fatalf("Get %s: %s", key, err)
+	}
+	if !bytes.HasPrefix(blob, encryptedMagic) {
+		t.Fatalf("%s is not encrypted: %q", key, blob)
+	}
+	rest := blob[len(encryptedMagic):]
+	return string(rest[1 : 1+int(rest[0])])
+}
+
+func TestEncryptedStoreRoundTrip(t *testing.T) {
+	local := newTestLocalClient(t)
+	store := newTestEncryptedStore(t, local, testKey("k1", 1))
+
+	secret := []byte(`{"channels": {"C1": {"languages": ["English", "Spanish"]}}}`)
+	if err := store.Set("store.json", secret); err != nil {
+		t.Fatalf("Set: %s", err)
+	}
+
+	blob, err := local.Get("store.json")
+	if err != nil {
+		t.Fatalf("Get: %s", err)
+	}
+	if bytes.Contains(blob, []byte("Spanish")) {
+		t.Errorf("stored blob contains the plaintext: %q", blob)
+	}
+
+	data, err := store.Get("store.json")
+	if err != nil {
+		t.Fatalf("Get: %s", err)
+	}
+	if !bytes.Equal(data, secret) {
+		t.Errorf("Get = %q, want %q", data, secret)
+	}
+
+	// Batches are encrypted too
+	if err := store.Update(func(batch Batch) error {
+		batch.Set("teams/T1", secret)
+		return nil
+	}); err != nil {
+		t.Fatalf("Update: %s", err)
+	}
+	if id := keyID(t, local, "teams/T1"); id != "k1" {
+		t.Errorf("batch blob key = %s, want k1", id)
+	}
+}
+
+func TestEncryptedStoreFailsClosed(t *testing.T) {
+	local := newTestLocalClient(t)
+	if err := newTestEncryptedStore(t, local, testKey("k1", 1)).Set("store.json", []byte("secret")); err != nil {
+		t.Fatalf("Set: %s", err)
+	}
+	blob, err := local.Get("store.json")
+	if err != nil {
+		t.Fatalf("Get: %s", err)
+	}
+
+	tests := []struct {
+		name  string
+		keys  []string
+		blob  []byte
+		key   string
+		error string
+	}{
+		{name: "wrong key", keys: []string{testKey("k1", 2)}, blob: blob, key: "store.json", error: "could not be decrypted"},
+		{name: "unknown key id", keys: []string{testKey("k2", 1)}, blob: blob, key: "store.json", error: "unknown key 'k1'"},
+		{name: "tampered data", keys: []string{testKey("k1", 1)}, blob: flipLastByte(blob), key: "store.json", error: "could not be decrypted"},
+		// The datastore key is authenticated, so blobs can't be moved
+		{name: "moved blob", keys: []string{testKey("k1", 1)}, blob: blob, key: "teams/T1", error: "could not be decrypted"},
+		{name: "truncated", keys: []string{testKey("k1", 1)}, blob: blob[:len(encryptedMagic)+4], key: "store.json", error: "truncated"},
+		{name: "malformed header", keys: []string{testKey("k1", 1)}, blob: encryptedMagic, key: "store.json", error: "malformed header"},
+		{name: "plaintext", keys: []string{testKey("k1", 1)}, blob: []byte(`{"channels": {}}`), key: "store.json", error: "not encrypted"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			local := newTestLocalClient(t)
+			if err := local.Set(tt.key, tt.blob); err != nil {
+				t.Fatalf("Set: %s", err)
+			}
+
+			data, err := newTestEncryptedStore(t, local, tt.keys...).Get(tt.key)
+			if errors.Cause(err) != ErrDecryption || !strings.Contains(err.Error(), tt.error) {
+				t.Fatalf("Get = %q, %v; want ErrDecryption (%s)", data, err, tt.error)
+			}
+
+			// Nothing is written back
+			stored, err := local.Get(tt.key)
+			if err != nil || !bytes.Equal(stored, tt.blob) {
+				t.Errorf("stored blob changed to %q (%v)", stored, err)
+			}
+		})
+	}
+}
+
+func flipLastByte(blob []byte) []byte {
+	tampered := append([]byte{}, blob...)
+	tampered[len(tampered)-1] ^= 0xff
+	return tampered
+}
+
+func TestEncryptedStoreRotation(t *testing.T) {
+	local := newTestLocalClient(t)
+	if err := newTestEncryptedStore(t, local, testKey("old", 1)).Set("store.json", []byte("secret")); err != nil {
+		t.Fatalf("Set: %s", err)
+	}
+
+	// The new key is prepended, keeping the old one to read existing data
+	store := newTestEncryptedStore(t, local, testKey("new", 2), testKey("old", 1))
+	data, err := store.Get("store.json")
+	if err != nil || string(data) != "secret" {
+		t.Fatalf("Get = %q, %v", data, err)
+	}
+	if id := keyID(t, local, "store.json"); id != "new" {
+		t.Errorf("blob is encrypted with %s after reading, want new", id)
+	}
+
+	// The old key can then be dropped
+	data, err = newTestEncryptedStore(t, local, testKey("new", 2)).Get("store.json")
+	if err != nil || string(data) != "secret" {
+		t.Fatalf("Get without the old key = %q, %v", data, err)
+	}
+}
+
+func TestEncryptPlaintext(t *testing.T) {
+	local := newTestLocalClient(t)
+	for key, data := range map[string]string{"store.json": "config", "teams/T1": "token"} {
+		if err := local.Set(key, []byte(data)); err != nil {
+			t.Fatalf("Set: %s", err)
+		}
+	}
+	store := newTestEncryptedStore(t, local, testKey("k1", 1))
+	if err := store.Set("teams/T2", []byte("encrypted")); err != nil {
+		t.Fatalf("Set: %s", err)
+	}
+
+	encrypted, err := store.EncryptPlaintext()
+	if err != nil {
+		t.Fatalf("EncryptPlaintext: %s", err)
+	}
+	if want := []string{"store.json", "teams/T1"}; !reflect.DeepEqual(encrypted, want) {
+		t.Errorf("EncryptPlaintext = %q, want %q", encrypted, want)
+	}
+
+	for key, want := range map[string]string{"store.json": "config", "teams/T1": "token", "teams/T2": "encrypted"} {
+		if id := keyID(t, local, key); id != "k1" {
+			t.Errorf("%s is encrypted with %s, want k1", key, id)
+		}
+		if data, err := store.Get(key); err != nil || string(data) != want {
+			t.Errorf("Get %s = %q, %v; want %q", key, data, err, want)
+		}
+	}
+
+	// Running it again changes nothing
+	if encrypted, err := store.EncryptPlaintext(); err != nil || len(encrypted) != 0 {
+		t.Errorf("second EncryptPlaintext = %q, %v", encrypted, err)
+	}
+}
+
+func TestNewEncryptedStoreKeys(t *testing.T) {
+	tests := []struct {
+		name  string
+		keys  string
+		error string
+	}{
+		{name: "none", keys: "", error: "no encryption key given"},
+		{name: "comments only", keys: "# k1:...\n", error: "no encryption key given"},
+		{name: "missing id", keys: "bm90IGEga2V5", error: "<id>:<base64 key>"},
+		{name: "invalid base64", keys: "k1:???", error: "not valid base64"},
+		{name: "invalid length", keys: "k1:" + base64.StdEncoding.EncodeToString([]byte("short")), error: "invalid encryption key 'k1'"},
+		{name: "duplicate", keys: testKey("k1", 1) + "\n" + testKey("k1", 2), error: "duplicate encryption key 'k1'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewEncryptedStore(&NooPClient{}, tt.keys); err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("NewEncryptedStore error = %v, want %q", err, tt.error)
+			}
+		})
+	}
+}
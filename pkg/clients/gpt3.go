@@ -4,14 +4,15 @@
  * File Created: Wednesday, 25th January 2023 3:02:02 pm
  * Author: Mark Mester (mmester6016@gmail.com)
  * -----
- * Last Modified: Thursday, 2nd February 2023 10:19:42 pm
+ * Last Modified: Saturday, 17th October 2026 6:23:08 am
  * Modified By: Mark Mester (mmester6016@gmail.com>)
  */
 package clients
 
 import (
 	"context"
-	"fmt"
+	stdErrors "errors"
+	"net/http"
 	"time"
 
 	"github.com/PullRequestInc/go-gpt3"
@@ -22,43 +23,81 @@ type Gpt3Client struct {
 	gpt3.Client
 }
 
+// retryAfterKey is the context key under which a *time.Duration receives
+// the Retry-After of a throttled response, which go-gpt3 does not expose
+type retryAfterKey struct{}
+
+// retryAfterTransport records the Retry-After header of responses into the
+// request's context so it can be honoured when retrying
+type retryAfterTransport struct {
+	http.RoundTripper
+}
+
+func (t *retryAfterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.RoundTripper.RoundTrip(req)
+	if err == nil {
+		if retryAfter, ok := req.Context().Value(retryAfterKey{}).(*time.Duration); ok {
+			*retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+		}
+	}
+	return resp, err
+}
+
 func NewGpt3Client(chatGptApiKey, chatGptEngine string) *Gpt3Client {
 	return &Gpt3Client{
-		gpt3.NewClient(chatGptApiKey, gpt3.WithDefaultEngine(chatGptEngine)),
+		gpt3.NewClient(chatGptApiKey,
+			gpt3.WithDefaultEngine(chatGptEngine),
+			gpt3.WithHTTPClient(&http.Client{
+				Transport: &retryAfterTransport{http.DefaultTransport},
+				Timeout:   requestTimeout,
+			}),
+		),
 	}
 }
 
-func (g *Gpt3Client) Translate(fromLanguage, fromDialect, toLanguage, toDialect, msg string) (string, error) {
-	ask := ""
-
-	switch {
-	case fromDialect != "" && toDialect != "":
-		ask = fmt.Sprintf("Translate this from %s (%s) to %s (%s): %s", fromLanguage, fromDialect, toLanguage, toDialect, msg)
-	case fromDialect != "" && toDialect == "":
-		ask = fmt.Sprintf("Translate this from %s (%s) to %s: %s", fromLanguage, fromDialect, toLanguage, msg)
-	case fromDialect == "" && toDialect != "":
-		ask = fmt.Sprintf("Translate this from %s to %s(%s): %s", fromLanguage, toLanguage, toDialect, msg)
-	case fromDialect == "" && toDialect == "":
-		ask = fmt.Sprintf("Translate this from %s to %s: %s", fromLanguage, toLanguage, msg)
-	default:
-		return "", fmt.Errorf("from and to language must be defined")
+func (g *Gpt3Client) Translate(ctx context.Context, fromLanguage, fromDialect, toLanguage, toDialect, msg string, opts ...TranslateOption) (string, error) {
+	ask, err := translationPrompt(fromLanguage, fromDialect, toLanguage, toDialect, msg, opts...)
+	if err != nil {
+		return "", err
 	}
 
-	ctx, cancel := context.WithTimeout(context.TODO(), time.Second*60)
-	defer cancel()
+	return g.complete(ctx, ask)
+}
+
+func (g *Gpt3Client) Explain(ctx context.Context, fromLanguage, toLanguage, msg, translation string) (string, error) {
+	return g.complete(ctx, explanationPrompt(fromLanguage, toLanguage, msg, translation))
+}
 
-	resp, err := g.Completion(ctx, gpt3.CompletionRequest{
-		Prompt:           []string{ask},
-		MaxTokens:        gpt3.IntPtr(512),
-		Temperature:      gpt3.Float32Ptr(0.3),
-		TopP:             gpt3.Float32Ptr(1),
-		FrequencyPenalty: 0,
-		PresencePenalty:  0,
-		Echo:             false,
+// complete returns the completion of the prompt
+func (g *Gpt3Client) complete(ctx context.Context, prompt string) (string, error) {
+	var resp *gpt3.CompletionResponse
+	err := retry(ctx, DefaultRetryPolicy, func(ctx context.Context) error {
+		var retryAfter time.Duration
+		ctx = context.WithValue(ctx, retryAfterKey{}, &retryAfter)
+
+		r, err := g.Completion(ctx, gpt3.CompletionRequest{
+			Prompt:           []string{prompt},
+			MaxTokens:        gpt3.IntPtr(512),
+			Temperature:      gpt3.Float32Ptr(0.3),
+			TopP:             gpt3.Float32Ptr(1),
+			FrequencyPenalty: 0,
+			PresencePenalty:  0,
+			Echo:             false,
+		})
+		resp = r
+
+		var apiErr gpt3.APIError
+		if stdErrors.As(err, &apiErr) {
+			return &HTTPError{StatusCode: apiErr.StatusCode, Body: apiErr.Message, RetryAfter: retryAfter}
+		}
+		return err
 	})
 	if err != nil {
 		return "", errors.Wrap(err, "ChatGPT completion API error")
 	}
+	if len(resp.Choices) == 0 {
+		return "", errors.New("ChatGPT completion API returned no choices")
+	}
 
 	return resp.Choices[0].Text, nil
 }
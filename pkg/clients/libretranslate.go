@@ -0,0 +1,74 @@
+/*
+ * File: libretranslate.go
+ * Project: clients
+ * File Created: Saturday, 17th October 2026 6:14:30 am
+ * Author: Mark Mester (mmester6016@gmail.com)
+ * -----
+ * Last Modified: Saturday, 17th October 2026 6:23:08 am
+ * Modified By: Mark Mester (mmester6016@gmail.com>)
+ */
+package clients
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// LibreTranslateClient translates using a (typically self-hosted)
+// LibreTranslate server. See https://github.com/LibreTranslate/LibreTranslate
+type LibreTranslateClient struct {
+	http *http.Client
+
+	baseURL string
+	apiKey  string
+}
+
+type libreTranslateRequest struct {
+	Q      string `json:"q"`
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Format string `json:"format"`
+	APIKey string `json:"api_key,omitempty"`
+}
+
+type libreTranslateResponse struct {
+	TranslatedText string `json:"translatedText"`
+}
+
+func NewLibreTranslateClient(baseURL, apiKey string) *LibreTranslateClient {
+	return &LibreTranslateClient{
+		http:    &http.Client{},
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+	}
+}
+
+// Translate translates msg; LibreTranslate has no notion of dialects or
+// feedback so those are ignored
+func (l *LibreTranslateClient) Translate(ctx context.Context, fromLanguage, _, toLanguage, _, msg string, _ ...TranslateOption) (string, error) {
+	target := LanguageCode(toLanguage)
+	if target == "" {
+		return "", fmt.Errorf("unsupported target language '%s'", toLanguage)
+	}
+	source := LanguageCode(fromLanguage)
+	if source == "" {
+		source = "auto"
+	}
+
+	var resp libreTranslateResponse
+	if err := postJSON(ctx, l.http, l.baseURL+"/translate", nil, libreTranslateRequest{
+		Q:      msg,
+		Source: source,
+		Target: target,
+		Format: "text",
+		APIKey: l.apiKey,
+	}, &resp); err != nil {
+		return "", errors.Wrap(err, "LibreTranslate API error")
+	}
+
+	return resp.TranslatedText, nil
+}
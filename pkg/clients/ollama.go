@@ -0,0 +1,81 @@
+/*
+ * File: ollama.go
+ * Project: clients
+ * File Created: Saturday, 17th October 2026 6:14:30 am
+ * Author: Mark Mester (mmester6016@gmail.com)
+ * -----
+ * Last Modified: Saturday, 17th October 2026 6:23:08 am
+ * Modified By: Mark Mester (mmester6016@gmail.com>)
+ */
+package clients
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultOllamaURL is the address a local ollama server listens on
+const DefaultOllamaURL = "http://localhost:11434"
+
+// OllamaClient translates using a self-hosted model served by ollama.
+// See https://github.com/jmorganca/ollama/blob/main/docs/api.md
+type OllamaClient struct {
+	http *http.Client
+
+	baseURL string
+	model   string
+}
+
+type ollamaGenerateRequest struct {
+	Model   string                 `json:"model"`
+	Prompt  string                 `json:"prompt"`
+	Stream  bool                   `json:"stream"`
+	Options map[string]interface{} `json:"options,omitempty"`
+}
+
+type ollamaGenerateResponse struct {
+	Response string `json:"response"`
+}
+
+func NewOllamaClient(baseURL, model string) *OllamaClient {
+	if baseURL == "" {
+		baseURL = DefaultOllamaURL
+	}
+
+	return &OllamaClient{
+		http:    &http.Client{},
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		model:   model,
+	}
+}
+
+func (o *OllamaClient) Translate(ctx context.Context, fromLanguage, fromDialect, toLanguage, toDialect, msg string, opts ...TranslateOption) (string, error) {
+	ask, err := translationPrompt(fromLanguage, fromDialect, toLanguage, toDialect, msg, opts...)
+	if err != nil {
+		return "", err
+	}
+
+	return o.generate(ctx, ask+"\nRespond with only the translation.")
+}
+
+func (o *OllamaClient) Explain(ctx context.Context, fromLanguage, toLanguage, msg, translation string) (string, error) {
+	return o.generate(ctx, explanationPrompt(fromLanguage, toLanguage, msg, translation))
+}
+
+// generate returns the model's response to the prompt
+func (o *OllamaClient) generate(ctx context.Context, prompt string) (string, error) {
+	var resp ollamaGenerateResponse
+	if err := postJSON(ctx, o.http, o.baseURL+"/api/generate", nil, ollamaGenerateRequest{
+		Model:   o.model,
+		Prompt:  prompt,
+		Stream:  false,
+		Options: map[string]interface{}{"temperature": 0.3},
+	}, &resp); err != nil {
+		return "", errors.Wrap(err, "ollama generate API error")
+	}
+
+	return strings.TrimSpace(resp.Response), nil
+}
@@ -0,0 +1,129 @@
+/*
+ * File: retry.go
+ * Project: clients
+ * File Created: Saturday, 17th October 2026 6:16:39 am
+ * Author: Mark Mester (mmester6016@gmail.com)
+ * -----
+ * Last Modified: Saturday, 17th October 2026 6:16:39 am
+ * Modified By: Mark Mester (mmester6016@gmail.com>)
+ */
+package clients
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how failed API calls are retried. Delays grow
+// exponentially from BaseDelay up to MaxDelay with full jitter; a server
+// supplied Retry-After always takes precedence.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy is used by the translation clients
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+}
+
+// retry calls fn until it succeeds, returns a permanent error, the attempts
+// are exhausted or ctx is done
+func retry(ctx context.Context, policy RetryPolicy, fn func(ctx context.Context) error) error {
+	var err error
+	for attempt := 0; attempt < policy.MaxAttempts; attempt++ {
+		if err = fn(ctx); err == nil {
+			return nil
+		}
+
+		retryable, retryAfter := isRetryable(err)
+		if !retryable || ctx.Err() != nil || attempt == policy.MaxAttempts-1 {
+			break
+		}
+
+		delay := retryAfter
+		if delay == 0 {
+			delay = backoff(policy, attempt)
+		} else if delay > policy.MaxDelay {
+			return fmt.Errorf("server requested retry after %s: %w", delay, err)
+		}
+
+		log.Printf("retryable error on attempt %d/%d, retrying in %s; err=%s", attempt+1, policy.MaxAttempts, delay, err.Error())
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return err
+}
+
+// backoff returns a jittered exponential delay for the given attempt
+func backoff(policy RetryPolicy, attempt int) time.Duration {
+	ceiling := policy.BaseDelay << attempt
+	if ceiling <= 0 || ceiling > policy.MaxDelay {
+		ceiling = policy.MaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)) + 1)
+}
+
+// isRetryable reports whether err is transient, and how long the server
+// asked us to wait before retrying, if at all
+func isRetryable(err error) (bool, time.Duration) {
+	if errors.Is(err, context.Canceled) {
+		return false, 0
+	}
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		switch {
+		case httpErr.StatusCode == http.StatusTooManyRequests,
+			httpErr.StatusCode == http.StatusRequestTimeout,
+			httpErr.StatusCode >= 500:
+			return true, httpErr.RetryAfter
+		default:
+			// Authentication, permission and invalid requests won't succeed on retry
+			return false, 0
+		}
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true, 0
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true, 0
+	}
+
+	return false, 0
+}
+
+// parseRetryAfter parses a Retry-After header given in either seconds or as an HTTP date
+func parseRetryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
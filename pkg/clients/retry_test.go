@@ -0,0 +1,192 @@
+/*
+ * File: retry_test.go
+ * Project: clients
+ * File Created: Saturday, 17th October 2026 7:24:57 am
+ * Author: Mark Mester (mmester6016@gmail.com)
+ * -----
+ * Last Modified: Saturday, 17th October 2026 7:24:57 am
+ * Modified By: Mark Mester (mmester6016@gmail.com>)
+ */
+package clients
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		min    time.Duration
+		max    time.Duration
+	}{
+		{name: "empty", header: ""},
+		{name: "seconds", header: "5", min: 5 * time.Second, max: 5 * time.Second},
+		{name: "zero seconds", header: "0"},
+		{name: "negative seconds", header: "-3"},
+		{name: "invalid", header: "soon"},
+		{name: "http date", header: time.Now().Add(time.Minute).UTC().Format(http.TimeFormat), min: 58 * time.Second, max: time.Minute},
+		{name: "past http date", header: time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if d := parseRetryAfter(tt.header); d < tt.min || d > tt.max {
+				t.Errorf("parseRetryAfter(%q) = %s, want between %s and %s", tt.header, d, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		retryable  bool
+		retryAfter time.Duration
+	}{
+		{name: "too many requests", err: &HTTPError{StatusCode: http.StatusTooManyRequests, RetryAfter: 3 * time.Second}, retryable: true, retryAfter: 3 * time.Second},
+		{name: "request timeout", err: &HTTPError{StatusCode: http.StatusRequestTimeout}, retryable: true},
+		{name: "server error", err: &HTTPError{StatusCode: http.StatusBadGateway}, retryable: true},
+		{name: "wrapped server error", err: fmt.Errorf("translating: %w", &HTTPError{StatusCode: http.StatusServiceUnavailable}), retryable: true},
+		{name: "bad request", err: &HTTPError{StatusCode: http.StatusBadRequest}},
+		{name: "unauthorized", err: &HTTPError{StatusCode: http.StatusUnauthorized}},
+		{name: "forbidden", err: &HTTPError{StatusCode: http.StatusForbidden, RetryAfter: time.Second}},
+		{name: "not found", err: &HTTPError{StatusCode: http.StatusNotFound}},
+		{name: "cancelled", err: context.Canceled},
+		{name: "deadline", err: context.DeadlineExceeded, retryable: true},
+		{name: "network", err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, retryable: true},
+		{name: "invalid json", err: errors.New("invalid json response")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retryable, retryAfter := isRetryable(tt.err)
+			if retryable != tt.retryable || retryAfter != tt.retryAfter {
+				t.Errorf("isRetryable = %t, %s; want %t, %s", retryable, retryAfter, tt.retryable, tt.retryAfter)
+			}
+		})
+	}
+}
+
+var testRetryPolicy = RetryPolicy{MaxAttempts: 4, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+// failing returns fn for retry that fails with errs in turn, then succeeds
+func failing(attempts *int, errs ...error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		*attempts++
+		if *attempts <= len(errs) {
+			return errs[*attempts-1]
+		}
+		return nil
+	}
+}
+
+func TestRetry(t *testing.T) {
+	unavailable := &HTTPError{StatusCode: http.StatusServiceUnavailable}
+	unauthorized := &HTTPError{StatusCode: http.StatusUnauthorized}
+
+	tests := []struct {
+		name     string
+		errs     []error
+		err      error
+		attempts int
+	}{
+		{name: "success", attempts: 1},
+		{name: "transient", errs: []error{unavailable, context.DeadlineExceeded}, attempts: 3},
+		{name: "permanent", errs: []error{unauthorized}, err: unauthorized, attempts: 1},
+		{name: "permanent after transient", errs: []error{unavailable, unauthorized}, err: unauthorized, attempts: 2},
+		{name: "exhausted", errs: []error{unavailable, unavailable, unavailable, unavailable, unavailable}, err: unavailable, attempts: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			if err := retry(context.Background(), testRetryPolicy, failing(&attempts, tt.errs...)); err != tt.err {
+				t.Errorf("retry = %v, want %v", err, tt.err)
+			}
+			if attempts != tt.attempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Second}
+
+	attempts := 0
+	start := time.Now()
+	err := retry(context.Background(), policy, failing(&attempts, &HTTPError{StatusCode: http.StatusTooManyRequests, RetryAfter: 50 * time.Millisecond}))
+	if err != nil || attempts != 2 {
+		t.Fatalf("retry = %v after %d attempts, want success after 2", err, attempts)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("retried after %s, before the requested 50ms", elapsed)
+	}
+
+	// A server asking for longer than the policy allows isn't waited for
+	attempts = 0
+	err = retry(context.Background(), policy, failing(&attempts, &HTTPError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Hour}))
+	if err == nil || !strings.Contains(err.Error(), "server requested retry after 1h0m0s") || attempts != 1 {
+		t.Errorf("retry = %v after %d attempts, want the requested delay after 1", err, attempts)
+	}
+}
+
+func TestRetryCancelled(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 4, BaseDelay: time.Hour, MaxDelay: time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	attempts := 0
+	done := make(chan error)
+	go func() {
+		done <- retry(ctx, policy, failing(&attempts, &HTTPError{StatusCode: http.StatusServiceUnavailable}))
+	}()
+
+	// Cancelling during the backoff returns straight away
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("retry = %v, want context.Canceled", err)
+		}
+		if attempts != 1 {
+			t.Errorf("attempts = %d, want 1", attempts)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("retry kept backing off after its context was cancelled")
+	}
+
+	// A call that fails because the context was cancelled isn't retried
+	attempts = 0
+	err := retry(ctx, testRetryPolicy, func(ctx context.Context) error {
+		attempts++
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.Canceled) || attempts != 1 {
+		t.Errorf("retry = %v after %d attempts, want context.Canceled after 1", err, attempts)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt := 0; attempt < 70; attempt++ {
+		ceiling := policy.MaxDelay
+		if attempt < 4 {
+			ceiling = policy.BaseDelay << attempt
+		}
+		for i := 0; i < 100; i++ {
+			if d := backoff(policy, attempt); d <= 0 || d > ceiling {
+				t.Fatalf("backoff(%d) = %s, want within (0, %s]", attempt, d, ceiling)
+			}
+		}
+	}
+}
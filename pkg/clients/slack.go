@@ -4,13 +4,16 @@
  * File Created: Wednesday, 25th January 2023 2:56:57 pm
  * Author: Mark Mester (mmester6016@gmail.com)
  * -----
- * Last Modified: Wednesday, 25th January 2023 8:15:51 pm
+ * Last Modified: Saturday, 17th October 2026 7:02:14 am
  * Modified By: Mark Mester (mmester6016@gmail.com>)
  */
 package clients
 
 import (
+	"context"
 	"log"
+	"net/http"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -21,40 +24,126 @@ import (
 type SlackClient struct {
 	client *slack.Client
 	socket *socketmode.Client
+
+	// events forwards socket events until the client is closed
+	events chan socketmode.Event
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// server receives events through the Events API instead of socket mode
+	server        *http.Server
+	mux           *http.ServeMux
+	signingSecret string
+}
+
+// SlackUser is the attribution shown for a user's messages
+type SlackUser struct {
+	Name     string
+	ImageURL string
 }
 
 type slackMsg struct {
-	Text      string
-	Timestamp string
+	User            string
+	Text            string
+	Timestamp       string
+	ThreadTimestamp string
+	BotID           string
+	Blocks          slack.Blocks
 }
 
 func NewSlackClient(slackBotToken, slackAppToken string) *SlackClient {
 	client := slack.New(slackBotToken, slack.OptionAppLevelToken(slackAppToken))
 	socket := socketmode.New(client)
 
-	// TODO: graceful shutdown of this routine
+	return &SlackClient{
+		client: client,
+		socket: socket,
+		events: make(chan socketmode.Event),
+	}
+}
+
+// Start connects to slack, delivering events on Events until ctx is
+// cancelled or the client is closed
+func (s *SlackClient) Start(ctx context.Context) {
+	ctx, s.cancel = context.WithCancel(ctx)
+
+	if s.server != nil {
+		s.serve(ctx)
+		return
+	}
+
+	s.wg.Add(2)
 	go func() {
+		defer s.wg.Done()
+
 		log.Println("Starting slack runloop")
 		for {
-			if err := socket.Run(); err != nil {
+			if err := s.socket.RunContext(ctx); err != nil && ctx.Err() == nil {
 				log.Println("error connecting to slack; retrying in 10 seconds...")
-				time.Sleep(10 * time.Second)
+			}
+			select {
+			case <-ctx.Done():
+				log.Println("Stopped slack runloop")
+				return
+			case <-time.After(10 * time.Second):
 			}
 		}
 	}()
 
-	return &SlackClient{
-		client: client,
-		socket: socket,
+	go func() {
+		defer s.wg.Done()
+		defer close(s.events)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case evt := <-s.socket.Events:
+				select {
+				case s.events <- evt:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+}
+
+// Close disconnects from slack and closes Events
+func (s *SlackClient) Close() {
+	if s.cancel != nil {
+		s.cancel()
 	}
+	s.wg.Wait()
+}
+
+// Events delivers the events received from slack; it is closed by Close
+func (s *SlackClient) Events() <-chan socketmode.Event {
+	return s.events
 }
 
 func (s *SlackClient) Sock() *socketmode.Client {
 	return s.socket
 }
 
-func (s *SlackClient) PostMessage(channelId string, options ...slack.MsgOption) error {
-	if _, _, err := s.socket.PostMessage(channelId, options...); err != nil {
+// PostMessage posts a message to the channel, returning its timestamp
+func (s *SlackClient) PostMessage(channelId string, options ...slack.MsgOption) (string, error) {
+	_, timestamp, err := s.socket.PostMessage(channelId, options...)
+	if err != nil {
+		return "", err
+	}
+	return timestamp, nil
+}
+
+func (s *SlackClient) UpdateMessage(channelId, timestamp string, options ...slack.MsgOption) error {
+	if _, _, _, err := s.socket.UpdateMessage(channelId, timestamp, options...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *SlackClient) DeleteMessage(channelId, timestamp string) error {
+	if _, _, err := s.socket.DeleteMessage(channelId, timestamp); err != nil {
 		return err
 	}
 	return nil
@@ -67,7 +156,19 @@ func (s *SlackClient) PostEphemeralMessage(channelId, userId string, options ...
 	return nil
 }
 
+func (s *SlackClient) OpenView(triggerID string, view slack.ModalViewRequest) error {
+	if _, err := s.socket.OpenView(triggerID, view); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Ack acknowledges a socket mode request; Events API requests are
+// acknowledged by the response to them
 func (s *SlackClient) Ack(ack socketmode.Request, payload ...interface{}) {
+	if s.server != nil {
+		return
+	}
 	s.socket.Ack(ack, payload...)
 }
 
@@ -89,10 +190,12 @@ func (s *SlackClient) GetMessage(id string, timestamp string) (*slackMsg, error)
 	slMsg := &slackMsg{}
 	for _, i := range msg {
 		slMsg.Timestamp = i.Timestamp
+		slMsg.ThreadTimestamp = i.ThreadTimestamp
 		if slMsg.Timestamp == "" {
 			slMsg.Timestamp = i.ThreadTimestamp
 		}
 
+		slMsg.Blocks = i.Blocks
 		slMsg.Text = i.Text
 		if slMsg.Text == "" {
 			for _, j := range i.Attachments {
@@ -104,3 +207,131 @@ func (s *SlackClient) GetMessage(id string, timestamp string) (*slackMsg, error)
 
 	return slMsg, nil
 }
+
+// https://api.slack.com/methods/users.info
+func (s *SlackClient) GetUser(id string) (*SlackUser, error) {
+	user, err := s.client.GetUserInfo(id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get slack user")
+	}
+
+	name := user.Profile.DisplayName
+	if name == "" {
+		name = user.RealName
+	}
+	if name == "" {
+		name = user.Name
+	}
+
+	return &SlackUser{
+		Name:     name,
+		ImageURL: user.Profile.Image72,
+	}, nil
+}
+
+// https://api.slack.com/methods/conversations.list
+func (s *SlackClient) FindChannelByName(name string) (string, error) {
+	params := &slack.GetConversationsParameters{
+		ExcludeArchived: true,
+		Limit:           200,
+		Types:           []string{"public_channel", "private_channel"},
+	}
+
+	for {
+		channels, cursor, err := s.client.GetConversations(params)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to list slack channels")
+		}
+		for _, channel := range channels {
+			if channel.Name == name {
+				return channel.ID, nil
+			}
+		}
+		if cursor == "" {
+			return "", errors.Errorf("channel #%s not found", name)
+		}
+		params.Cursor = cursor
+	}
+}
+
+// GetHistory returns up to limit messages preceding timestamp, oldest first.
+// If thread is set, the preceding messages of that thread are returned
+// (https://api.slack.com/methods/conversations.replies), otherwise those of
+// the channel (https://api.slack.com/methods/conversations.history).
+func (s *SlackClient) GetHistory(channel, thread, timestamp string, limit int) ([]*slackMsg, error) {
+	var msgs []slack.Message
+	if thread != "" {
+		// Replies are returned oldest first, so page to the end and keep the tail
+		params := &slack.GetConversationRepliesParameters{
+			ChannelID: channel,
+			Timestamp: thread,
+			Latest:    timestamp,
+			Inclusive: false,
+			Limit:     200,
+		}
+		for {
+			replies, hasMore, cursor, err := s.client.GetConversationReplies(params)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to get slack thread history")
+			}
+			msgs = append(msgs, replies...)
+			if len(msgs) > limit {
+				msgs = msgs[len(msgs)-limit:]
+			}
+			if !hasMore || cursor == "" {
+				break
+			}
+			params.Cursor = cursor
+		}
+	} else {
+		resp, err := s.client.GetConversationHistory(&slack.GetConversationHistoryParameters{
+			ChannelID: channel,
+			Latest:    timestamp,
+			Inclusive: false,
+			Limit:     limit,
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get slack channel history")
+		}
+		// History is returned newest first
+		for i := len(resp.Messages) - 1; i >= 0; i-- {
+			msgs = append(msgs, resp.Messages[i])
+		}
+	}
+
+	history := []*slackMsg{}
+	for _, msg := range msgs {
+		// Latest is inclusive of the thread parent regardless of Inclusive
+		if msg.Timestamp == timestamp {
+			continue
+		}
+		history = append(history, &slackMsg{
+			User:      msg.User,
+			Text:      msg.Text,
+			Timestamp: msg.Timestamp,
+			BotID:     msg.BotID,
+		})
+	}
+	return history, nil
+}
+
+// https://api.slack.com/methods/conversations.members
+func (s *SlackClient) GetMembers(channel string) ([]string, error) {
+	params := &slack.GetUsersInConversationParameters{
+		ChannelID: channel,
+		Limit:     200,
+	}
+
+	members := []string{}
+	for {
+		page, cursor, err := s.client.GetUsersInConversation(params)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list slack channel members")
+		}
+		members = append(members, page...)
+		if cursor == "" {
+			return members, nil
+		}
+		params.Cursor = cursor
+	}
+}
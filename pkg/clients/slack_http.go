@@ -0,0 +1,217 @@
+/*
+ * File: slack_http.go
+ * Project: clients
+ * File Created: Saturday, 17th October 2026 6:39:55 am
+ * Author: Mark Mester (mmester6016@gmail.com)
+ * -----
+ * Last Modified: Saturday, 17th October 2026 6:42:10 am
+ * Modified By: Mark Mester (mmester6016@gmail.com>)
+ */
+package clients
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/slack-go/slack/socketmode"
+)
+
+const (
+	// DefaultSlackHTTPAddr is the address the Events API server listens on
+	DefaultSlackHTTPAddr = ":3000"
+
+	// Request paths to configure as the app's request URLs
+	SlackEventsPath        = "/slack/events"
+	SlackCommandsPath      = "/slack/commands"
+	SlackInteractivityPath = "/slack/interactivity"
+
+	// Slack expects a response within 3 seconds
+	slackResponseTimeout = 2500 * time.Millisecond
+	// Request bodies are limited to 1MB
+	maxSlackRequestSize = 1 << 20
+)
+
+// NewSlackHTTPClient creates a client receiving events through the public
+// Events API: slack sends signed HTTP requests to the server listening on
+// addr, which are delivered on Events just like socket mode events.
+func NewSlackHTTPClient(slackBotToken, signingSecret, addr string) *SlackClient {
+	if addr == "" {
+		addr = DefaultSlackHTTPAddr
+	}
+
+	client := slack.New(slackBotToken)
+	s := &SlackClient{
+		client: client,
+		// Only used for the web API; it never connects
+		socket:        socketmode.New(client),
+		events:        make(chan socketmode.Event),
+		signingSecret: signingSecret,
+	}
+
+	s.mux = http.NewServeMux()
+	s.mux.HandleFunc(SlackEventsPath, s.verified(s.handleEvents))
+	s.mux.HandleFunc(SlackCommandsPath, s.verified(s.handleCommands))
+	s.mux.HandleFunc(SlackInteractivityPath, s.verified(s.handleInteractivity))
+	s.server = &http.Server{
+		Addr:              addr,
+		Handler:           s.mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	return s
+}
+
+// Handle serves additional requests, e.g. the OAuth install flow, on the
+// Events API server
+func (s *SlackClient) Handle(pattern string, handler http.Handler) error {
+	if s.mux == nil {
+		return errors.New("requests can only be served in events API mode")
+	}
+	s.mux.Handle(pattern, handler)
+	return nil
+}
+
+// serve runs the Events API server until ctx is cancelled
+func (s *SlackClient) serve(ctx context.Context) {
+	s.wg.Add(2)
+	go func() {
+		defer s.wg.Done()
+
+		log.Printf("Starting slack events server on %s", s.server.Addr)
+		if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("slack events server failed: %s", err.Error())
+		}
+	}()
+
+	go func() {
+		defer s.wg.Done()
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := s.server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("error shutting down slack events server: %s", err.Error())
+		}
+
+		// Handlers have returned, so nothing sends on events anymore
+		close(s.events)
+		log.Println("Stopped slack events server")
+	}()
+}
+
+// verified rejects requests without a valid X-Slack-Signature, and passes
+// the body of the others to handler
+func (s *SlackClient) verified(handler func(w http.ResponseWriter, r *http.Request, body []byte)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxSlackRequestSize))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		verifier, err := slack.NewSecretsVerifier(r.Header, s.signingSecret)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if _, err := verifier.Write(body); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if err := verifier.Ensure(); err != nil {
+			log.Printf("rejected slack request with invalid signature from %s", r.RemoteAddr)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		// Form handlers parse the body again
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		handler(w, r, body)
+	}
+}
+
+func (s *SlackClient) handleEvents(w http.ResponseWriter, r *http.Request, body []byte) {
+	event, err := slackevents.ParseEvent(body, slackevents.OptionNoVerifyToken())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if event.Type == slackevents.URLVerification {
+		var challenge slackevents.ChallengeResponse
+		if err := json.Unmarshal(body, &challenge); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(challenge.Challenge))
+		return
+	}
+
+	s.deliver(w, r, socketmode.Event{
+		Type:    socketmode.EventTypeEventsAPI,
+		Data:    event,
+		Request: &socketmode.Request{Type: socketmode.RequestTypeEventsAPI},
+	})
+}
+
+func (s *SlackClient) handleCommands(w http.ResponseWriter, r *http.Request, _ []byte) {
+	command, err := slack.SlashCommandParse(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s.deliver(w, r, socketmode.Event{
+		Type:    socketmode.EventTypeSlashCommand,
+		Data:    command,
+		Request: &socketmode.Request{Type: socketmode.RequestTypeSlashCommands},
+	})
+}
+
+func (s *SlackClient) handleInteractivity(w http.ResponseWriter, r *http.Request, _ []byte) {
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var interaction slack.InteractionCallback
+	if err := json.Unmarshal([]byte(r.PostForm.Get("payload")), &interaction); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s.deliver(w, r, socketmode.Event{
+		Type:    socketmode.EventTypeInteractive,
+		Data:    interaction,
+		Request: &socketmode.Request{Type: socketmode.RequestTypeInteractive},
+	})
+}
+
+// deliver passes the event on to Events and acknowledges the request. If it
+// can't be passed on in time, slack is asked to retry it later.
+func (s *SlackClient) deliver(w http.ResponseWriter, r *http.Request, evt socketmode.Event) {
+	ctx, cancel := context.WithTimeout(r.Context(), slackResponseTimeout)
+	defer cancel()
+
+	select {
+	case s.events <- evt:
+		w.WriteHeader(http.StatusOK)
+	case <-ctx.Done():
+		log.Printf("unable to deliver slack %s event in time; asking slack to retry", evt.Type)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+}
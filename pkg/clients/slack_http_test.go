@@ -0,0 +1,218 @@
+/*
+ * File: slack_http_test.go
+ * Project: clients
+ * File Created: Saturday, 17th October 2026 7:25:36 am
+ * Author: Mark Mester (mmester6016@gmail.com)
+ * -----
+ * Last Modified: Saturday, 17th October 2026 7:25:36 am
+ * Modified By: Mark Mester (mmester6016@gmail.com>)
+ */
+package clients
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/slack-go/slack/socketmode"
+)
+
+const testSigningSecret = "8f742231b10e8888abcd99yyyzzz85a5"
+
+// signedRequest builds a request to path signed with secret at the given time
+func signedRequest(path, contentType, body, secret string, at time.Time) *http.Request {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + timestamp + ":" + body))
+
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-Slack-Request-Timestamp", timestamp)
+	req.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+	return req
+}
+
+const urlVerification = `{"token": "legacy", "challenge": "3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P", "type": "url_verification"}`
+
+func TestSlackHTTPVerification(t *testing.T) {
+	tests := []struct {
+		name   string
+		req    func() *http.Request
+		status int
+	}{
+		{
+			name: "valid",
+			req: func() *http.Request {
+				return signedRequest(SlackEventsPath, "application/json", urlVerification, testSigningSecret, time.Now())
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "bad signature",
+			req: func() *http.Request {
+				return signedRequest(SlackEventsPath, "application/json", urlVerification, "not the signing secret", time.Now())
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "tampered body",
+			req: func() *http.Request {
+				req := signedRequest(SlackEventsPath, "application/json", urlVerification, testSigningSecret, time.Now())
+				req.Body = io.NopCloser(strings.NewReader(strings.Replace(urlVerification, "3eZ", "4eZ", 1)))
+				return req
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "stale timestamp",
+			req: func() *http.Request {
+				return signedRequest(SlackEventsPath, "application/json", urlVerification, testSigningSecret, time.Now().Add(-10*time.Minute))
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "unsigned",
+			req: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, SlackEventsPath, strings.NewReader(urlVerification))
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "unsigned command",
+			req: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, SlackCommandsPath, strings.NewReader("command=%2Ffanyi"))
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "get",
+			req: func() *http.Request {
+				return httptest.NewRequest(http.MethodGet, SlackEventsPath, nil)
+			},
+			status: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSlackHTTPClient("xoxb-test", testSigningSecret, "")
+			w := httptest.NewRecorder()
+			s.mux.ServeHTTP(w, tt.req())
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if tt.status != http.StatusOK && w.Body.Len() != 0 {
+				t.Errorf("rejected request got a body: %q", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestSlackHTTPURLVerification(t *testing.T) {
+	s := NewSlackHTTPClient("xoxb-test", testSigningSecret, "")
+	w := httptest.NewRecorder()
+	s.mux.ServeHTTP(w, signedRequest(SlackEventsPath, "application/json", urlVerification, testSigningSecret, time.Now()))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if challenge := w.Body.String(); challenge != "3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P" {
+		t.Errorf("challenge = %q", challenge)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "text/plain" {
+		t.Errorf("Content-Type = %q, want text/plain", contentType)
+	}
+}
+
+func TestSlackHTTPDelivery(t *testing.T) {
+	interaction := url.Values{"payload": {`{"type": "block_actions", "trigger_id": "T123", "user": {"id": "U1"}}`}}.Encode()
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		check       func(t *testing.T, evt socketmode.Event)
+	}{
+		{
+			name:        "event",
+			path:        SlackEventsPath,
+			contentType: "application/json",
+			body:        `{"type": "event_callback", "team_id": "T1", "event": {"type": "message", "channel": "C1", "user": "U1", "text": "hello", "ts": "1.2"}}`,
+			check: func(t *testing.T, evt socketmode.Event) {
+				event, ok := evt.Data.(slackevents.EventsAPIEvent)
+				if evt.Type != socketmode.EventTypeEventsAPI || !ok {
+					t.Fatalf("event %s with %T", evt.Type, evt.Data)
+				}
+				message, ok := event.InnerEvent.Data.(*slackevents.MessageEvent)
+				if event.TeamID != "T1" || !ok || message.Text != "hello" {
+					t.Errorf("unexpected event %+v", event)
+				}
+			},
+		},
+		{
+			name:        "command",
+			path:        SlackCommandsPath,
+			contentType: "application/x-www-form-urlencoded",
+			body:        "command=%2Ffanyi&text=on&channel_id=C1&user_id=U1&team_id=T1",
+			check: func(t *testing.T, evt socketmode.Event) {
+				command, ok := evt.Data.(slack.SlashCommand)
+				if evt.Type != socketmode.EventTypeSlashCommand || !ok {
+					t.Fatalf("event %s with %T", evt.Type, evt.Data)
+				}
+				if command.Command != "/fanyi" || command.Text != "on" || command.ChannelID != "C1" {
+					t.Errorf("unexpected command %+v", command)
+				}
+			},
+		},
+		{
+			name:        "interaction",
+			path:        SlackInteractivityPath,
+			contentType: "application/x-www-form-urlencoded",
+			body:        interaction,
+			check: func(t *testing.T, evt socketmode.Event) {
+				callback, ok := evt.Data.(slack.InteractionCallback)
+				if evt.Type != socketmode.EventTypeInteractive || !ok {
+					t.Fatalf("event %s with %T", evt.Type, evt.Data)
+				}
+				if callback.Type != slack.InteractionTypeBlockActions || callback.TriggerID != "T123" || callback.User.ID != "U1" {
+					t.Errorf("unexpected interaction %+v", callback)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSlackHTTPClient("xoxb-test", testSigningSecret, "")
+			received := make(chan socketmode.Event, 1)
+			go func() {
+				received <- <-s.Events()
+			}()
+
+			w := httptest.NewRecorder()
+			s.mux.ServeHTTP(w, signedRequest(tt.path, tt.contentType, tt.body, testSigningSecret, time.Now()))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", w.Code)
+			}
+
+			select {
+			case evt := <-received:
+				tt.check(t, evt)
+			case <-time.After(time.Second):
+				t.Fatalf("no event was delivered")
+			}
+		})
+	}
+}
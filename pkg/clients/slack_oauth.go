@@ -0,0 +1,321 @@
+/*
+ * File: slack_oauth.go
+ * Project: clients
+ * File Created: Saturday, 17th October 2026 6:42:10 am
+ * Author: Mark Mester (mmester6016@gmail.com)
+ * -----
+ * Last Modified: Saturday, 17th October 2026 7:07:22 am
+ * Modified By: Mark Mester (mmester6016@gmail.com>)
+ */
+package clients
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/pkg/errors"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/socketmode"
+)
+
+const (
+	// Request paths of the OAuth v2 install flow
+	SlackInstallPath       = "/slack/install"
+	SlackOAuthRedirectPath = "/slack/oauth_redirect"
+
+	// teamsNamespace holds the installation of each workspace
+	teamsNamespace = "teams"
+	// Install attempts must be completed within 10 minutes
+	oauthStateExpireDuration = 10 * time.Minute
+	// oauthStateCookie binds the state of an install to the browser starting it
+	oauthStateCookie = "fanyi_oauth_state"
+)
+
+// DefaultSlackScopes are the bot scopes requested on installation; they
+// match manifest.yml
+var DefaultSlackScopes = []string{
+	"channels:history",
+	"channels:read",
+	"chat:write",
+	"chat:write.customize",
+	"commands",
+	"conversations.connect:read",
+	"groups:history",
+	"groups:read",
+	"reactions:read",
+	"users:read",
+}
+
+// SlackInstallation is the bot token of a workspace (or, for org wide
+// installs, an enterprise) the app is installed in
+type SlackInstallation struct {
+	TeamID       string    `json:"team_id"`
+	TeamName     string    `json:"team_name"`
+	EnterpriseID string    `json:"enterprise_id,omitempty"`
+	BotUserID    string    `json:"bot_user_id"`
+	BotToken     string    `json:"bot_token"`
+	Scope        string    `json:"scope"`
+	InstalledAt  time.Time `json:"installed_at"`
+}
+
+// ID is the team ID, or the enterprise ID of org wide installs
+func (i SlackInstallation) ID() string {
+	if i.TeamID != "" {
+		return i.TeamID
+	}
+	return i.EnterpriseID
+}
+
+// SlackPool holds a client per workspace the app is installed in, and stores
+// their tokens in the datastore. Events of every workspace are received by
+// a single receiving client.
+type SlackPool struct {
+	mu      sync.RWMutex
+	clients map[string]*SlackClient
+
+	receiver  *SlackClient
+	datastore DataStore
+}
+
+// NewSlackPool loads the stored installations
+func NewSlackPool(receiver *SlackClient, datastore DataStore) (*SlackPool, error) {
+	p := &SlackPool{
+		clients:   map[string]*SlackClient{},
+		receiver:  receiver,
+		datastore: datastore,
+	}
+
+	installations, err := ReadInstallations(datastore)
+	if err != nil {
+		return nil, err
+	}
+	for id, installation := range installations {
+		p.clients[id] = NewSlackWebClient(installation.BotToken)
+	}
+
+	log.Printf("Loaded slack installations of %d workspaces", len(p.clients))
+	return p, nil
+}
+
+// Receiver returns the client receiving the events of all workspaces
+func (p *SlackPool) Receiver() *SlackClient {
+	return p.receiver
+}
+
+// Client returns the client of a workspace, falling back to the client of
+// its enterprise for org wide installs
+func (p *SlackPool) Client(teamID, enterpriseID string) (*SlackClient, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if client, ok := p.clients[teamID]; ok && teamID != "" {
+		return client, true
+	}
+	if client, ok := p.clients[enterpriseID]; ok && enterpriseID != "" {
+		return client, true
+	}
+	return nil, false
+}
+
+// Install stores the installation and adds a client for it
+func (p *SlackPool) Install(installation SlackInstallation) error {
+	data, err := json.Marshal(installation)
+	if err != nil {
+		return err
+	}
+	if err := p.datastore.Set(InstallationKey(installation.ID()), data); err != nil {
+		return errors.Wrapf(err, "unable to store installation")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.clients[installation.ID()] = NewSlackWebClient(installation.BotToken)
+	log.Printf("Installed in workspace %s (%s)", installation.TeamName, installation.ID())
+	return nil
+}
+
+// Uninstall forgets the workspace's token
+func (p *SlackPool) Uninstall(id string) error {
+	p.mu.Lock()
+	delete(p.clients, id)
+	p.mu.Unlock()
+
+	log.Printf("Uninstalled from workspace %s", id)
+	return p.datastore.Delete(InstallationKey(id))
+}
+
+// InstallationKey is the datastore key of the installation of a workspace
+func InstallationKey(id string) string {
+	return Key(teamsNamespace, id)
+}
+
+// ReadInstallations returns the stored installations by workspace ID
+func ReadInstallations(datastore DataStore) (map[string]SlackInstallation, error) {
+	keys, err := datastore.List(teamsNamespace + KeySeparator)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list installations")
+	}
+
+	installations := map[string]SlackInstallation{}
+	for _, key := range keys {
+		data, err := datastore.Get(key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to read installation %s", key)
+		}
+
+		var installation SlackInstallation
+		if err := json.Unmarshal(data, &installation); err != nil {
+			return nil, errors.Wrapf(err, "invalid installation %s", key)
+		}
+		installations[installation.ID()] = installation
+	}
+	return installations, nil
+}
+
+// NewSlackWebClient creates a client that only calls the web API; its
+// events are received by another client
+func NewSlackWebClient(slackBotToken string) *SlackClient {
+	client := slack.New(slackBotToken)
+	return &SlackClient{
+		client: client,
+		socket: socketmode.New(client),
+	}
+}
+
+// ============= OAuth ============= //
+
+// SlackOAuthConfig holds the app credentials of the OAuth v2 install flow
+type SlackOAuthConfig struct {
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the public URL of SlackOAuthRedirectPath
+	RedirectURL string
+	Scopes      []string
+}
+
+// SlackOAuthHandler installs the app in workspaces: SlackInstallPath sends
+// the user to slack to authorize the app, which redirects back to
+// SlackOAuthRedirectPath with a code exchanged for the workspace's bot token
+type SlackOAuthHandler struct {
+	config SlackOAuthConfig
+	pool   *SlackPool
+
+	// states are the unguessable state parameters of pending installs
+	states *cache.Cache
+	http   *http.Client
+}
+
+func NewSlackOAuthHandler(config SlackOAuthConfig, pool *SlackPool) *SlackOAuthHandler {
+	if len(config.Scopes) == 0 {
+		config.Scopes = DefaultSlackScopes
+	}
+
+	return &SlackOAuthHandler{
+		config: config,
+		pool:   pool,
+		states: cache.New(oauthStateExpireDuration, oauthStateExpireDuration),
+		http:   &http.Client{Timeout: requestTimeout},
+	}
+}
+
+func (h *SlackOAuthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case SlackInstallPath:
+		h.install(w, r)
+	case SlackOAuthRedirectPath:
+		h.redirect(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *SlackOAuthHandler) install(w http.ResponseWriter, r *http.Request) {
+	state := make([]byte, 16)
+	if _, err := rand.Read(state); err != nil {
+		http.Error(w, "unable to start installation", http.StatusInternalServerError)
+		return
+	}
+	h.states.SetDefault(hex.EncodeToString(state), true)
+	h.setStateCookie(w, hex.EncodeToString(state), int(oauthStateExpireDuration.Seconds()))
+
+	query := url.Values{
+		"client_id":    {h.config.ClientID},
+		"scope":        {strings.Join(h.config.Scopes, ",")},
+		"redirect_uri": {h.config.RedirectURL},
+		"state":        {hex.EncodeToString(state)},
+	}
+	http.Redirect(w, r, "https://slack.com/oauth/v2/authorize?"+query.Encode(), http.StatusFound)
+}
+
+func (h *SlackOAuthHandler) redirect(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if reason := query.Get("error"); reason != "" {
+		http.Error(w, fmt.Sprintf("Installation was cancelled: %s", reason), http.StatusForbidden)
+		return
+	}
+
+	state := query.Get("state")
+	if _, ok := h.states.Get(state); !ok || state == "" {
+		http.Error(w, "Installation link expired; please try again.", http.StatusBadRequest)
+		return
+	}
+	// The state must have been issued to this browser, so a victim can't be
+	// made to complete an install started by someone else
+	cookie, err := r.Cookie(oauthStateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		http.Error(w, "Installation was started in another browser; please try again.", http.StatusForbidden)
+		return
+	}
+	h.states.Delete(state)
+	h.setStateCookie(w, "", -1)
+
+	resp, err := slack.GetOAuthV2ResponseContext(r.Context(), h.http, h.config.ClientID, h.config.ClientSecret, query.Get("code"), h.config.RedirectURL)
+	if err != nil {
+		log.Printf("unable to complete slack installation: %s", err.Error())
+		http.Error(w, "Unable to complete installation; please try again.", http.StatusBadGateway)
+		return
+	}
+
+	if err := h.pool.Install(SlackInstallation{
+		TeamID:       resp.Team.ID,
+		TeamName:     resp.Team.Name,
+		EnterpriseID: resp.Enterprise.ID,
+		BotUserID:    resp.BotUserID,
+		BotToken:     resp.AccessToken,
+		Scope:        resp.Scope,
+		InstalledAt:  time.Now().UTC(),
+	}); err != nil {
+		log.Printf("unable to store slack installation: %s", err.Error())
+		http.Error(w, "Unable to complete installation; please try again.", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "Fanyi has been installed in %s! Use /translate in a channel to get started.", resp.Team.Name)
+}
+
+// setStateCookie sets the state cookie, or deletes it if maxAge is negative.
+// It must be sent along with slack's redirect, a cross-site navigation, so
+// SameSite is lax rather than strict.
+func (h *SlackOAuthHandler) setStateCookie(w http.ResponseWriter, state string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    state,
+		Path:     SlackOAuthRedirectPath,
+		MaxAge:   maxAge,
+		Secure:   strings.HasPrefix(h.config.RedirectURL, "https://"),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
@@ -0,0 +1,206 @@
+/*
+ * File: translator.go
+ * Project: clients
+ * File Created: Saturday, 17th October 2026 6:14:30 am
+ * Author: Mark Mester (mmester6016@gmail.com)
+ * -----
+ * Last Modified: Saturday, 17th October 2026 6:24:23 am
+ * Modified By: Mark Mester (mmester6016@gmail.com>)
+ */
+package clients
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pemistahl/lingua-go"
+)
+
+// DefaultTranslationProvider is used when no provider has been configured
+const DefaultTranslationProvider = "gpt3"
+
+// Translator translates a message between two languages, optionally
+// targeting a specific dialect of either language. Cancelling ctx abandons
+// the translation, including any pending retries.
+type Translator interface {
+	Translate(ctx context.Context, fromLanguage, fromDialect, toLanguage, toDialect, msg string, opts ...TranslateOption) (string, error)
+}
+
+// Explainer is implemented by translators that can explain a translation
+type Explainer interface {
+	Explain(ctx context.Context, fromLanguage, toLanguage, msg, translation string) (string, error)
+}
+
+// TranslateOptions refine a translation request. Providers that can't make
+// use of an option ignore it.
+type TranslateOptions struct {
+	// Previous is an earlier translation of the message the user rejected
+	Previous string
+	// Feedback is the user's complaint about Previous
+	Feedback string
+	// History holds the preceding messages of the conversation, oldest first,
+	// which help resolve pronouns, slang and short replies
+	History []string
+}
+
+type TranslateOption func(*TranslateOptions)
+
+// WithFeedback asks for a different translation than previous, addressing the feedback
+func WithFeedback(previous, feedback string) TranslateOption {
+	return func(o *TranslateOptions) {
+		o.Previous = previous
+		o.Feedback = feedback
+	}
+}
+
+// WithHistory gives the translator the preceding messages of the conversation
+func WithHistory(history []string) TranslateOption {
+	return func(o *TranslateOptions) {
+		o.History = history
+	}
+}
+
+// WithOptions replaces all options with o
+func WithOptions(o TranslateOptions) TranslateOption {
+	return func(options *TranslateOptions) {
+		*options = o
+	}
+}
+
+// NewTranslateOptions applies opts to the default options
+func NewTranslateOptions(opts ...TranslateOption) TranslateOptions {
+	options := TranslateOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// TranslatorConfig holds the settings passed to a translation provider.
+// Providers ignore the fields they do not use.
+type TranslatorConfig struct {
+	APIKey  string
+	Model   string
+	BaseURL string
+}
+
+// TranslatorFactory builds a translation provider from its configuration
+type TranslatorFactory func(config TranslatorConfig) (Translator, error)
+
+var translatorProviders = map[string]TranslatorFactory{
+	"gpt3": func(config TranslatorConfig) (Translator, error) {
+		if config.APIKey == "" || config.Model == "" {
+			return nil, fmt.Errorf("gpt3 provider requires an api key and completion engine")
+		}
+		return NewGpt3Client(config.APIKey, config.Model), nil
+	},
+	"chat": func(config TranslatorConfig) (Translator, error) {
+		return NewChatClient(config.APIKey, config.Model, config.BaseURL), nil
+	},
+	"libretranslate": func(config TranslatorConfig) (Translator, error) {
+		if config.BaseURL == "" {
+			return nil, fmt.Errorf("libretranslate provider requires a base url")
+		}
+		return NewLibreTranslateClient(config.BaseURL, config.APIKey), nil
+	},
+	"ollama": func(config TranslatorConfig) (Translator, error) {
+		if config.Model == "" {
+			return nil, fmt.Errorf("ollama provider requires a model")
+		}
+		return NewOllamaClient(config.BaseURL, config.Model), nil
+	},
+}
+
+// RegisterTranslator makes a translation provider available to NewTranslator
+// under the given name, replacing any provider previously registered with it
+func RegisterTranslator(name string, factory TranslatorFactory) {
+	translatorProviders[strings.ToLower(name)] = factory
+}
+
+// TranslationProviders returns the names of all registered providers
+func TranslationProviders() []string {
+	names := []string{}
+	for name := range translatorProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewTranslator creates the translation provider registered under the given name
+func NewTranslator(provider string, config TranslatorConfig) (Translator, error) {
+	if provider == "" {
+		provider = DefaultTranslationProvider
+	}
+
+	factory, ok := translatorProviders[strings.ToLower(strings.TrimSpace(provider))]
+	if !ok {
+		return nil, fmt.Errorf("unknown translation provider '%s'; expected one of %s", provider, strings.Join(TranslationProviders(), ", "))
+	}
+
+	return factory(config)
+}
+
+// =========== Helpers ================ //
+
+// translationPrompt builds the instruction given to prompt based translation models
+func translationPrompt(fromLanguage, fromDialect, toLanguage, toDialect, msg string, opts ...TranslateOption) (string, error) {
+	if strings.Contains(msg, "{{") {
+		// Slack markup is swapped for placeholders before translation
+		msg = "(keep placeholders such as {{0}} exactly as they are) " + msg
+	}
+
+	var ask string
+	switch {
+	case fromLanguage == "" || toLanguage == "":
+		return "", fmt.Errorf("from and to language must be defined")
+	case fromDialect != "" && toDialect != "":
+		ask = fmt.Sprintf("Translate this from %s (%s) to %s (%s): %s", fromLanguage, fromDialect, toLanguage, toDialect, msg)
+	case fromDialect != "" && toDialect == "":
+		ask = fmt.Sprintf("Translate this from %s (%s) to %s: %s", fromLanguage, fromDialect, toLanguage, msg)
+	case fromDialect == "" && toDialect != "":
+		ask = fmt.Sprintf("Translate this from %s to %s (%s): %s", fromLanguage, toLanguage, toDialect, msg)
+	default:
+		ask = fmt.Sprintf("Translate this from %s to %s: %s", fromLanguage, toLanguage, msg)
+	}
+
+	options := NewTranslateOptions(opts...)
+	if len(options.History) > 0 {
+		ask = fmt.Sprintf("For context, these are the preceding messages of the conversation (do not translate them):\n%s\n\n%s",
+			strings.Join(options.History, "\n"), ask)
+	}
+	if options.Previous != "" {
+		ask += fmt.Sprintf("\n\nA previous translation was rejected by the reader: %s", options.Previous)
+		if options.Feedback != "" {
+			ask += fmt.Sprintf("\nThe reader's feedback: %s", options.Feedback)
+		}
+		ask += "\nProvide a different translation that addresses this."
+	}
+
+	return ask, nil
+}
+
+// explanationPrompt builds the instruction asking a model to explain a translation
+func explanationPrompt(fromLanguage, toLanguage, msg, translation string) string {
+	return fmt.Sprintf("The following %s message:\n%s\n\nwas translated to %s as:\n%s\n\n"+
+		"Briefly explain the translation in %s, pointing out idioms, slang, ambiguous words and any meaning that may have been lost.",
+		fromLanguage, msg, toLanguage, translation, toLanguage)
+}
+
+// LanguageCode maps a language name (e.g. "English" or "Chinese Simplified")
+// to its lowercase ISO 639-1 code, returning "" if it is unknown
+func LanguageCode(language string) string {
+	lang := stringToLang(language)
+	if lang == lingua.Unknown {
+		// Strip qualifiers such as "Simplified" / "Traditional"
+		if fields := strings.Fields(language); len(fields) > 1 {
+			lang = stringToLang(fields[0])
+		}
+	}
+	if lang == lingua.Unknown {
+		return ""
+	}
+	return strings.ToLower(lang.IsoCode639_1().String())
+}